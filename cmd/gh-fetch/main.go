@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/karthikraobr/gh-fetch/internal/cache"
 	"github.com/karthikraobr/gh-fetch/internal/gh"
 	"github.com/karthikraobr/gh-fetch/internal/handlers"
 	"github.com/karthikraobr/gh-fetch/internal/store"
+	"github.com/redis/go-redis/v9"
 )
 
+// maxStoredResponses caps the github responses kept in the store for conditional requests.
+const maxStoredResponses = 10000
+
 func main() {
 	user := os.Getenv("POSTGRES_USER")
 	password := os.Getenv("POSTGRES_PASSWORD")
@@ -25,7 +34,113 @@ func main() {
 		log.Fatal("could not initialize database")
 		return
 	}
-	h := handlers.New(gh.New(nil, log), log, store, cache.New(100, 60))
-	r := h.SetUpRouter()
-	log.Fatal(r.Run(":8000"))
+	cfg, err := gh.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("could not configure github client: ", err)
+		return
+	}
+	// Keeping the validators next to the repositories lets conditional requests survive restarts.
+	cfg.ResponseCache = store
+	responseMaxAge, err := durationFromEnv("RESPONSE_CACHE_MAX_AGE", 24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go pruneResponses(pruneCtx, store, responseMaxAge, log)
+	ttl, err := durationFromEnv("CACHE_TTL", 60*time.Second)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	staleTTL, err := durationFromEnv("CACHE_STALE_TTL", 5*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	negativeTTL, err := durationFromEnv("CACHE_NEGATIVE_TTL", 30*time.Second)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	breakerTimeout, err := durationFromEnv("GITHUB_BREAKER_OPEN_TIMEOUT", 30*time.Second)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	var c cache.Cache[string, any]
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		opt, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Fatal("invalid REDIS_URL: ", err)
+			return
+		}
+		client := redis.NewClient(opt)
+		defer client.Close()
+		c = cache.NewRedis[any](client, log, cache.RedisConfig{Prefix: "gh-fetch:", TTL: ttl, StaleTTL: staleTTL})
+	} else {
+		c = cache.NewWithConfig(cache.Config[string, any]{
+			MaxEntries: 1000,
+			MaxBytes:   64 << 20,
+			TTL:        ttl,
+			StaleTTL:   staleTTL,
+		})
+	}
+	defer c.Close()
+	fetcher := gh.NewBreaker(gh.New(nil, log, cfg), gh.BreakerConfig{
+		OpenTimeout: breakerTimeout,
+		OnStateChange: func(from, to gh.BreakerState) {
+			log.Printf("github circuit breaker %s -> %s", from, to)
+		},
+	})
+	h := handlers.New(fetcher, log, store, c).
+		WithAdminToken(os.Getenv("ADMIN_TOKEN")).
+		WithNegativeTTL(negativeTTL)
+	srv := &http.Server{Addr: ":8000", Handler: h.SetUpRouter()}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("could not shut down gracefully", err.Error())
+	}
+}
+
+// pruneResponses bounds the github responses kept in the store every hour until ctx is done.
+func pruneResponses(ctx context.Context, s *store.Store, maxAge time.Duration, log *log.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := s.PruneResponses(maxAge, maxStoredResponses); err != nil {
+			log.Println("could not prune github responses", err.Error())
+		} else if n > 0 {
+			log.Printf("pruned %d github responses", n)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// durationFromEnv parses the duration in the environment variable key, falling back to def when it is not set.
+func durationFromEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
 }
@@ -1,59 +1,391 @@
 package cache
 
 import (
+	"container/list"
+	"encoding/json"
 	"sync"
 	"time"
 )
 
+// defaultJanitorInterval is how often expired entries are removed when no interval is configured.
+const defaultJanitorInterval = time.Second
+
+// EvictionReason tells why an entry was removed from the cache.
+type EvictionReason int
+
+const (
+	// Expired entries outlived their TTL.
+	Expired EvictionReason = iota
+	// Evicted entries were the least recently used when the cache ran out of room.
+	Evicted
+)
+
+// State tells how fresh a cached value is.
+type State int
+
+const (
+	// Miss means there is no value.
+	Miss State = iota
+	// Fresh values are within their TTL.
+	Fresh
+	// Stale values outlived their TTL but are kept for another StaleTTL to be served while they are refreshed.
+	Stale
+)
+
+// Cache stores values of type V by keys of type K for a while. Implementations are safe for concurrent use.
+type Cache[K comparable, V any] interface {
+	// Get returns the value of k as long as it is fresh.
+	Get(k K) (V, bool)
+	// GetWithState returns the value of k, including stale values, along with its state.
+	GetWithState(k K) (V, State)
+	// Put adds or replaces the value of k, which is fresh for the default TTL of the cache.
+	Put(k K, v V)
+	// PutWithTTL adds or replaces the value of k, which is fresh for ttl.
+	PutWithTTL(k K, v V, ttl time.Duration)
+	// Delete removes the entry of k and reports whether there was one.
+	Delete(k K) bool
+	// DeleteFunc removes the entries whose key matches and returns how many there were.
+	DeleteFunc(match func(k K) bool) int
+	// Purge removes every entry.
+	Purge()
+	// Keys lists the keys of the cache, including those of stale entries.
+	Keys() []K
+	// Stats returns the counters of the cache.
+	Stats() Stats
+	// Close releases the resources of the cache.
+	Close()
+}
+
+// Stats are the counters of a cache since it was created.
+type Stats struct {
+	// Hits counts reads of fresh values.
+	Hits uint64 `json:"hits"`
+	// StaleHits counts reads of stale values.
+	StaleHits uint64 `json:"stale_hits"`
+	// Misses counts reads which found no value.
+	Misses uint64 `json:"misses"`
+	// Evictions counts entries removed to make room for others.
+	Evictions uint64 `json:"evictions"`
+	// Expirations counts entries removed because they outlived their TTL.
+	Expirations uint64 `json:"expirations"`
+	// Entries is the number of entries currently held.
+	Entries int `json:"entries"`
+}
+
+// HitRatio is the share of reads which found a value, fresh or stale.
+func (s Stats) HitRatio() float64 {
+	reads := s.Hits + s.StaleHits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.StaleHits) / float64(reads)
+}
+
+var (
+	_ Cache[string, any] = (*TTLCache[string, any])(nil)
+	_ Cache[string, any] = (*RedisCache[any])(nil)
+)
+
+// Config configures a TTLCache.
+type Config[K comparable, V any] struct {
+	// MaxEntries caps the number of entries, zero means unbounded.
+	MaxEntries int
+	// MaxBytes caps the approximate size of all values, zero means unbounded.
+	MaxBytes int
+	// Sizer estimates the size of a value in bytes. Defaults to the length of its JSON encoding.
+	Sizer func(v V) int
+	// TTL is how long entries put without an explicit TTL are fresh.
+	TTL time.Duration
+	// StaleTTL is how long entries are kept as stale once their TTL passed. Zero removes them right away.
+	StaleTTL time.Duration
+	// Sliding extends the life of an entry by its TTL on every access. By default entries
+	// expire their TTL after they were put, regardless of how often they are read.
+	Sliding bool
+	// OnEvict is called for every entry removed by the cache itself.
+	OnEvict func(k K, v V, reason EvictionReason)
+	// Now is the clock entries expire by. Defaults to time.Now.
+	Now func() time.Time
+	// JanitorInterval is how often expired entries are removed in the background. Defaults to a second,
+	// a negative interval disables the janitor. Expired entries are never returned regardless.
+	JanitorInterval time.Duration
+}
+
 //https://stackoverflow.com/questions/25484122/map-with-ttl-option-in-go
-type item struct {
-	value      interface{}
-	lastAccess int64
+type item[K comparable, V any] struct {
+	key        K
+	value      V
+	ttl        time.Duration
+	freshUntil time.Time
+	expiresAt  time.Time
+	size       int
 }
 
-type TTLCache struct {
-	m map[string]*item
-	l sync.Mutex
+// TTLCache is an in-memory LRU cache whose entries expire after their TTL.
+// Close must be called to stop the background janitor.
+type TTLCache[K comparable, V any] struct {
+	m     map[K]*list.Element
+	lru   *list.List
+	bytes int
+	cfg   Config[K, V]
+	l     sync.Mutex
+	stats Stats
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
 }
 
-func New(ln int, maxTTL int) (m *TTLCache) {
-	m = &TTLCache{m: make(map[string]*item, ln)}
-	go func() {
-		for now := range time.Tick(time.Second) {
-			m.l.Lock()
-			for k, v := range m.m {
-				if now.Unix()-v.lastAccess > int64(maxTTL) {
-					delete(m.m, k)
-				}
-			}
-			m.l.Unlock()
-		}
-	}()
+// New initializes a cache holding at most ln entries which expire maxTTL seconds after they were put.
+func New[K comparable, V any](ln int, maxTTL int) (m *TTLCache[K, V]) {
+	return NewWithConfig(Config[K, V]{MaxEntries: ln, TTL: time.Duration(maxTTL) * time.Second})
+}
+
+// NewWithConfig initializes a cache.
+func NewWithConfig[K comparable, V any](cfg Config[K, V]) (m *TTLCache[K, V]) {
+	if cfg.MaxBytes > 0 && cfg.Sizer == nil {
+		cfg.Sizer = jsonSize[V]
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.JanitorInterval == 0 {
+		cfg.JanitorInterval = defaultJanitorInterval
+	}
+	m = &TTLCache[K, V]{
+		m:       make(map[K]*list.Element, cfg.MaxEntries),
+		lru:     list.New(),
+		cfg:     cfg,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if cfg.JanitorInterval < 0 {
+		close(m.stopped)
+		return
+	}
+	go m.janitor()
 	return
 }
 
-func (m *TTLCache) Len() int {
+func (m *TTLCache[K, V]) janitor() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(m.cfg.JanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.removeExpired()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Close stops the background janitor and waits for it to return. It is safe to call Close more than once.
+func (m *TTLCache[K, V]) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	<-m.stopped
+}
+
+func (m *TTLCache[K, V]) Len() int {
+	m.l.Lock()
+	defer m.l.Unlock()
 	return len(m.m)
 }
 
-func (m *TTLCache) Put(k string, v interface{}) {
+// Put adds or replaces the value of k, which lives for the configured TTL.
+func (m *TTLCache[K, V]) Put(k K, v V) {
+	m.PutWithTTL(k, v, m.cfg.TTL)
+}
+
+// PutWithTTL adds or replaces the value of k, which lives for ttl.
+func (m *TTLCache[K, V]) PutWithTTL(k K, v V, ttl time.Duration) {
+	size := 0
+	if m.cfg.Sizer != nil {
+		size = m.cfg.Sizer(v)
+	}
 	m.l.Lock()
-	it, ok := m.m[k]
+	e, ok := m.m[k]
 	if !ok {
-		it = &item{value: v}
-		m.m[k] = it
+		e = m.lru.PushFront(&item[K, V]{key: k})
+		m.m[k] = e
+	} else {
+		m.lru.MoveToFront(e)
 	}
-	it.lastAccess = time.Now().Unix()
+	it := e.Value.(*item[K, V])
+	m.bytes += size - it.size
+	it.value, it.size, it.ttl = v, size, ttl
+	m.touch(it, m.cfg.Now())
+	evicted := m.shrink()
 	m.l.Unlock()
+	m.notify(evicted, Evicted)
 }
 
-func (m *TTLCache) Get(k string) (v interface{}) {
+// Get returns the value of k as long as it is fresh.
+func (m *TTLCache[K, V]) Get(k K) (V, bool) {
+	v, state := m.GetWithState(k)
+	if state != Fresh {
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+// GetWithState returns the value of k, including stale values, along with its state.
+func (m *TTLCache[K, V]) GetWithState(k K) (v V, state State) {
+	var expired []*item[K, V]
 	m.l.Lock()
-	if it, ok := m.m[k]; ok {
-		v = it.value
-		it.lastAccess = time.Now().Unix()
+	if e, ok := m.m[k]; ok {
+		it := e.Value.(*item[K, V])
+		now := m.cfg.Now()
+		switch {
+		case m.expired(it, now):
+			m.remove(e)
+			expired = append(expired, it)
+		case now.After(it.freshUntil):
+			v, state = it.value, Stale
+			m.lru.MoveToFront(e)
+		default:
+			v, state = it.value, Fresh
+			if m.cfg.Sliding {
+				m.touch(it, now)
+			}
+			m.lru.MoveToFront(e)
+		}
+	}
+	switch state {
+	case Fresh:
+		m.stats.Hits++
+	case Stale:
+		m.stats.StaleHits++
+	default:
+		m.stats.Misses++
 	}
+	m.stats.Expirations += uint64(len(expired))
 	m.l.Unlock()
-	return
+	m.notify(expired, Expired)
+	return v, state
+}
+
+// touch starts the TTL of an entry at now.
+func (m *TTLCache[K, V]) touch(it *item[K, V], now time.Time) {
+	it.freshUntil = now.Add(it.ttl)
+	it.expiresAt = it.freshUntil.Add(m.cfg.StaleTTL)
+}
+
+// Delete removes the entry of k and reports whether there was one.
+func (m *TTLCache[K, V]) Delete(k K) bool {
+	m.l.Lock()
+	defer m.l.Unlock()
+	e, ok := m.m[k]
+	if ok {
+		m.remove(e)
+	}
+	return ok
+}
+
+// DeleteFunc removes the entries whose key matches and returns how many there were.
+func (m *TTLCache[K, V]) DeleteFunc(match func(k K) bool) int {
+	m.l.Lock()
+	defer m.l.Unlock()
+	n := 0
+	for k, e := range m.m {
+		if match(k) {
+			m.remove(e)
+			n++
+		}
+	}
+	return n
+}
+
+// Keys lists the keys of the cache from the most to the least recently used.
+func (m *TTLCache[K, V]) Keys() []K {
+	m.l.Lock()
+	defer m.l.Unlock()
+	keys := make([]K, 0, m.lru.Len())
+	for e := m.lru.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*item[K, V]).key)
+	}
+	return keys
+}
 
+// Stats returns the counters of the cache.
+func (m *TTLCache[K, V]) Stats() Stats {
+	m.l.Lock()
+	defer m.l.Unlock()
+	s := m.stats
+	s.Entries = len(m.m)
+	return s
+}
+
+// Purge removes every entry.
+func (m *TTLCache[K, V]) Purge() {
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.m = make(map[K]*list.Element, m.cfg.MaxEntries)
+	m.lru.Init()
+	m.bytes = 0
+}
+
+func (m *TTLCache[K, V]) expired(it *item[K, V], now time.Time) bool {
+	return now.After(it.expiresAt)
+}
+
+// removeExpired removes every expired entry.
+func (m *TTLCache[K, V]) removeExpired() {
+	var expired []*item[K, V]
+	m.l.Lock()
+	now := m.cfg.Now()
+	for _, e := range m.m {
+		if it := e.Value.(*item[K, V]); m.expired(it, now) {
+			m.remove(e)
+			expired = append(expired, it)
+		}
+	}
+	m.stats.Expirations += uint64(len(expired))
+	m.l.Unlock()
+	m.notify(expired, Expired)
+}
+
+// shrink evicts the least recently used entries until the cache fits its limits.
+// The most recent entry is kept even when it alone exceeds MaxBytes.
+func (m *TTLCache[K, V]) shrink() (evicted []*item[K, V]) {
+	for m.lru.Len() > 1 && m.overLimit() {
+		e := m.lru.Back()
+		m.remove(e)
+		evicted = append(evicted, e.Value.(*item[K, V]))
+	}
+	m.stats.Evictions += uint64(len(evicted))
+	return evicted
+}
+
+func (m *TTLCache[K, V]) overLimit() bool {
+	return (m.cfg.MaxEntries > 0 && m.lru.Len() > m.cfg.MaxEntries) ||
+		(m.cfg.MaxBytes > 0 && m.bytes > m.cfg.MaxBytes)
+}
+
+func (m *TTLCache[K, V]) remove(e *list.Element) {
+	it := e.Value.(*item[K, V])
+	m.lru.Remove(e)
+	delete(m.m, it.key)
+	m.bytes -= it.size
+}
+
+// notify calls OnEvict outside of the lock, so that callbacks may use the cache.
+func (m *TTLCache[K, V]) notify(items []*item[K, V], reason EvictionReason) {
+	if m.cfg.OnEvict == nil {
+		return
+	}
+	for _, it := range items {
+		m.cfg.OnEvict(it.key, it.value, reason)
+	}
+}
+
+func jsonSize[V any](v V) int {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
 }
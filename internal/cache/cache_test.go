@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTTLCache_LRU(t *testing.T) {
+	type eviction struct {
+		Key    string
+		Reason EvictionReason
+	}
+	tests := map[string]struct {
+		cfg      Config[string, int]
+		want     []string
+		wantGone []string
+		evicted  []eviction
+	}{
+		"max-entries": {
+			cfg:      Config[string, int]{MaxEntries: 2},
+			want:     []string{"a", "c"},
+			wantGone: []string{"b"},
+			evicted:  []eviction{{"b", Evicted}},
+		},
+		"max-bytes": {
+			cfg:      Config[string, int]{MaxBytes: 2, Sizer: func(int) int { return 1 }},
+			want:     []string{"a", "c"},
+			wantGone: []string{"b"},
+			evicted:  []eviction{{"b", Evicted}},
+		},
+		"unbounded": {
+			cfg:  Config[string, int]{},
+			want: []string{"a", "b", "c"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var evicted []eviction
+			tt.cfg.TTL = time.Hour
+			tt.cfg.OnEvict = func(k string, v int, reason EvictionReason) {
+				evicted = append(evicted, eviction{k, reason})
+			}
+			c := NewWithConfig(tt.cfg)
+			defer c.Close()
+			c.Put("a", 1)
+			c.Put("b", 2)
+			// Reading a makes b the least recently used entry.
+			c.Get("a")
+			c.Put("c", 3)
+			for _, k := range tt.want {
+				if _, ok := c.Get(k); !ok {
+					t.Errorf("Get(%q) = false, want value", k)
+				}
+			}
+			for _, k := range tt.wantGone {
+				if v, ok := c.Get(k); ok {
+					t.Errorf("Get(%q) = %v, want evicted", k, v)
+				}
+			}
+			if c.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", c.Len(), len(tt.want))
+			}
+			if !cmp.Equal(evicted, tt.evicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestTTLCache_oversizedEntry(t *testing.T) {
+	c := NewWithConfig(Config[string, string]{MaxBytes: 4, TTL: time.Hour})
+	defer c.Close()
+	c.Put("small", "a")
+	c.Put("large", "abcdefgh")
+	if _, ok := c.Get("small"); ok {
+		t.Errorf("want only the most recent entry to be kept")
+	}
+	if _, ok := c.Get("large"); !ok {
+		t.Errorf("want only the most recent entry to be kept")
+	}
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestTTLCache_Expiry(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		var expired []string
+		c := NewWithConfig(Config[string, int]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1, OnEvict: func(k string, v int, reason EvictionReason) {
+			if reason == Expired {
+				expired = append(expired, k)
+			}
+		}})
+		defer c.Close()
+		c.Put("a", 1)
+		clock.Add(time.Minute)
+		if _, ok := c.Get("a"); !ok {
+			t.Errorf("Get() = false, want entry to live for the TTL")
+		}
+		clock.Add(time.Minute + time.Second)
+		if v, ok := c.Get("a"); ok {
+			t.Errorf("Get() = %v, want expired", v)
+		}
+		if !cmp.Equal(expired, []string{"a"}) || c.Len() != 0 {
+			t.Errorf("expired = %v with %d entries left, want a removed", expired, c.Len())
+		}
+	})
+
+	t.Run("absolute", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := NewWithConfig(Config[string, int]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1})
+		defer c.Close()
+		c.Put("hot", 1)
+		for i := 0; i < 6; i++ {
+			clock.Add(10 * time.Second)
+			if _, ok := c.Get("hot"); !ok {
+				t.Fatalf("Get() = false after %d reads, want entry to live for the TTL", i)
+			}
+		}
+		clock.Add(time.Second)
+		if v, ok := c.Get("hot"); ok {
+			t.Errorf("Get() = %v, want hot entry to expire after the TTL", v)
+		}
+	})
+
+	t.Run("per-entry-ttl", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := NewWithConfig(Config[string, int]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1})
+		defer c.Close()
+		c.PutWithTTL("short", 1, time.Second)
+		c.Put("default", 2)
+		clock.Add(2 * time.Second)
+		_, short := c.Get("short")
+		_, def := c.Get("default")
+		if short || !def {
+			t.Errorf("want only the short lived entry to expire")
+		}
+	})
+
+	t.Run("sliding-janitor", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := NewWithConfig(Config[string, int]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1, Sliding: true})
+		defer c.Close()
+		c.Put("a", 1)
+		c.Put("b", 2)
+		clock.Add(30 * time.Second)
+		c.Get("b")
+		clock.Add(31 * time.Second)
+		c.removeExpired()
+		if _, ok := c.Get("b"); c.Len() != 1 || !ok {
+			t.Errorf("Len() = %d, want only the recently accessed entry", c.Len())
+		}
+	})
+}
+
+func TestTTLCache_GetWithState(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := NewWithConfig(Config[string, int]{TTL: time.Minute, StaleTTL: time.Minute, Now: clock.Now, JanitorInterval: -1})
+	defer c.Close()
+	c.Put("a", 1)
+	tests := []struct {
+		after     time.Duration
+		want      int
+		wantState State
+	}{
+		{after: 0, want: 1, wantState: Fresh},
+		{after: time.Minute + time.Second, want: 1, wantState: Stale},
+		{after: time.Minute, want: 0, wantState: Miss},
+	}
+	for _, tt := range tests {
+		clock.Add(tt.after)
+		v, state := c.GetWithState("a")
+		if v != tt.want || state != tt.wantState {
+			t.Errorf("GetWithState() after %v = %v, %v, want %v, %v", tt.after, v, state, tt.want, tt.wantState)
+		}
+	}
+	c.Put("b", 2)
+	clock.Add(time.Minute + time.Second)
+	if v, ok := c.Get("b"); ok {
+		t.Errorf("Get() = %v, want stale values to be hidden", v)
+	}
+}
+
+func TestTTLCache_Put(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := NewWithConfig(Config[string, string]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1, MaxBytes: 100})
+	defer c.Close()
+	c.Put("a", "old")
+	clock.Add(50 * time.Second)
+	c.Put("a", "new value")
+	if v, _ := c.Get("a"); v != "new value" {
+		t.Errorf("Get() = %v, want the replaced value", v)
+	}
+	if c.bytes != jsonSize("new value") {
+		t.Errorf("bytes = %d, want the size of the replaced value", c.bytes)
+	}
+	clock.Add(50 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Get() = false, want replacing to restart the TTL")
+	}
+}
+
+func TestTTLCache_Delete(t *testing.T) {
+	c := NewWithConfig(Config[string, int]{TTL: time.Minute, JanitorInterval: -1})
+	defer c.Close()
+	c.Put("a", 1)
+	c.Put("b", 2)
+	if !c.Delete("a") || c.Delete("a") {
+		t.Errorf("Delete() want true for present and false for missing entries")
+	}
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Errorf("want a deleted")
+	}
+	c.Purge()
+	if _, ok := c.Get("b"); ok || c.Len() != 0 {
+		t.Errorf("want every entry purged")
+	}
+}
+
+func TestTTLCache_Close(t *testing.T) {
+	c := NewWithConfig(Config[string, int]{TTL: time.Minute, JanitorInterval: time.Millisecond})
+	c.Close()
+	c.Close()
+	select {
+	case <-c.stopped:
+	default:
+		t.Error("janitor still running after Close()")
+	}
+}
+
+func TestTTLCache_Stats(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c := NewWithConfig(Config[string, int]{MaxEntries: 2, TTL: time.Minute, StaleTTL: time.Minute, Now: clock.Now, JanitorInterval: -1})
+	defer c.Close()
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")
+	c.Get("missing")
+	c.Put("c", 3)
+	clock.Add(time.Minute + time.Second)
+	c.GetWithState("a")
+	clock.Add(time.Minute)
+	c.GetWithState("c")
+	want := Stats{Hits: 1, StaleHits: 1, Misses: 2, Evictions: 1, Expirations: 1, Entries: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := want.HitRatio(); got != 0.5 {
+		t.Errorf("HitRatio() = %v, want 0.5", got)
+	}
+}
+
+func TestTTLCache_DeleteFunc(t *testing.T) {
+	c := NewWithConfig(Config[string, int]{TTL: time.Minute, JanitorInterval: -1})
+	defer c.Close()
+	c.Put("me/a", 1)
+	c.Put("me/b", 2)
+	c.Put("you/a", 3)
+	if n := c.DeleteFunc(func(k string) bool { return strings.HasPrefix(k, "me/") }); n != 2 {
+		t.Errorf("DeleteFunc() = %d, want 2", n)
+	}
+	if keys := c.Keys(); !cmp.Equal(keys, []string{"you/a"}) {
+		t.Errorf("Keys() = %v, want only the entries of you", keys)
+	}
+}
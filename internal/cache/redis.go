@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds a single call to redis, the cache interface does not carry a context.
+const redisTimeout = time.Second
+
+// RedisConfig configures a RedisCache.
+type RedisConfig struct {
+	// Prefix namespaces the keys of the cache. Purge removes every key with the prefix.
+	Prefix string
+	// TTL is how long entries put without an explicit TTL are fresh.
+	TTL time.Duration
+	// StaleTTL is how long entries are kept as stale once their TTL passed.
+	StaleTTL time.Duration
+	// Now is the clock freshness is judged by. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// RedisCache keeps entries in redis, so that every replica of the service shares them.
+// Values are gob encoded, concrete types stored behind an interface type V must be registered
+// with gob.Register. Redis errors are logged and otherwise treated as misses.
+type RedisCache[V any] struct {
+	client redis.UniversalClient
+	log    *log.Logger
+	cfg    RedisConfig
+
+	// Reads are counted by every replica on its own. Redis expires and evicts entries by itself,
+	// which is why neither is counted.
+	hits, staleHits, misses uint64
+}
+
+// redisEntry is the encoded form of a value.
+type redisEntry[V any] struct {
+	Value      V
+	FreshUntil time.Time
+}
+
+// NewRedis initializes a cache backed by client.
+func NewRedis[V any](client redis.UniversalClient, log *log.Logger, cfg RedisConfig) *RedisCache[V] {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &RedisCache[V]{client: client, log: log, cfg: cfg}
+}
+
+func (r *RedisCache[V]) key(k string) string {
+	return r.cfg.Prefix + k
+}
+
+// Put adds or replaces the value of k, which is fresh for the configured TTL.
+func (r *RedisCache[V]) Put(k string, v V) {
+	r.PutWithTTL(k, v, r.cfg.TTL)
+}
+
+// PutWithTTL adds or replaces the value of k, which is fresh for ttl.
+func (r *RedisCache[V]) PutWithTTL(k string, v V, ttl time.Duration) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&redisEntry[V]{Value: v, FreshUntil: r.cfg.Now().Add(ttl)}); err != nil {
+		r.log.Println("could not encode cache entry", k, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Set(ctx, r.key(k), buf.Bytes(), ttl+r.cfg.StaleTTL).Err(); err != nil {
+		r.log.Println("could not write cache entry", k, err.Error())
+	}
+}
+
+// Get returns the value of k as long as it is fresh.
+func (r *RedisCache[V]) Get(k string) (V, bool) {
+	v, state := r.GetWithState(k)
+	if state != Fresh {
+		var zero V
+		return zero, false
+	}
+	return v, true
+}
+
+// GetWithState returns the value of k, including stale values, along with its state.
+func (r *RedisCache[V]) GetWithState(k string) (V, State) {
+	var zero V
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	b, err := r.client.Get(ctx, r.key(k)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			r.log.Println("could not read cache entry", k, err.Error())
+		}
+		atomic.AddUint64(&r.misses, 1)
+		return zero, Miss
+	}
+	var e redisEntry[V]
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		r.log.Println("could not decode cache entry", k, err.Error())
+		atomic.AddUint64(&r.misses, 1)
+		return zero, Miss
+	}
+	if r.cfg.Now().After(e.FreshUntil) {
+		atomic.AddUint64(&r.staleHits, 1)
+		return e.Value, Stale
+	}
+	atomic.AddUint64(&r.hits, 1)
+	return e.Value, Fresh
+}
+
+// Delete removes the entry of k and reports whether there was one.
+func (r *RedisCache[V]) Delete(k string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := r.client.Del(ctx, r.key(k)).Result()
+	if err != nil {
+		r.log.Println("could not delete cache entry", k, err.Error())
+	}
+	return n > 0
+}
+
+// DeleteFunc removes the entries whose key matches and returns how many there were.
+func (r *RedisCache[V]) DeleteFunc(match func(k string) bool) int {
+	keys, err := r.scan()
+	if err != nil {
+		r.log.Println("could not list cache entries", err.Error())
+		return 0
+	}
+	var matched []string
+	for _, k := range keys {
+		if match(k) {
+			matched = append(matched, r.key(k))
+		}
+	}
+	return r.del(matched)
+}
+
+// Purge removes every entry with the prefix of the cache.
+func (r *RedisCache[V]) Purge() {
+	r.DeleteFunc(func(string) bool { return true })
+}
+
+// Keys lists the keys of the cache without its prefix.
+func (r *RedisCache[V]) Keys() []string {
+	keys, err := r.scan()
+	if err != nil {
+		r.log.Println("could not list cache entries", err.Error())
+	}
+	return keys
+}
+
+// Stats returns the reads counted by this replica along with the number of entries in redis.
+func (r *RedisCache[V]) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&r.hits),
+		StaleHits: atomic.LoadUint64(&r.staleHits),
+		Misses:    atomic.LoadUint64(&r.misses),
+		Entries:   len(r.Keys()),
+	}
+}
+
+// scan lists the keys with the prefix of the cache, the prefix stripped.
+func (r *RedisCache[V]) scan() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	var keys []string
+	iter := r.client.Scan(ctx, 0, escapeGlob(r.cfg.Prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), r.cfg.Prefix))
+	}
+	return keys, iter.Err()
+}
+
+// del removes keys and returns how many there were.
+func (r *RedisCache[V]) del(keys []string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	n, err := r.client.Del(ctx, keys...).Result()
+	if err != nil {
+		r.log.Println("could not delete cache entries", err.Error())
+	}
+	return int(n)
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapeGlob escapes the characters redis treats as patterns in MATCH.
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
+
+// Close does nothing, the redis client is owned by the caller.
+func (r *RedisCache[V]) Close() {}
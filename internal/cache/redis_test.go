@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/go-redis/v9"
+)
+
+type testPage struct {
+	Names []string
+	At    time.Time
+}
+
+func newTestRedis(t *testing.T, clock *fakeClock) (*RedisCache[*testPage], *miniredis.Miniredis) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedis[*testPage](client, log.New(ioutil.Discard, "", 0), RedisConfig{
+		Prefix:   "test:",
+		TTL:      time.Minute,
+		StaleTTL: time.Minute,
+		Now:      clock.Now,
+	}), s
+}
+
+func TestRedisCache_GetWithState(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, s := newTestRedis(t, clock)
+	want := &testPage{Names: []string{"blog", "site"}, At: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	c.Put("a", want)
+	tests := []struct {
+		after     time.Duration
+		want      *testPage
+		wantState State
+	}{
+		{after: 0, want: want, wantState: Fresh},
+		{after: time.Minute + time.Second, want: want, wantState: Stale},
+		{after: time.Minute, want: nil, wantState: Miss},
+	}
+	for _, tt := range tests {
+		clock.Add(tt.after)
+		s.FastForward(tt.after)
+		v, state := c.GetWithState("a")
+		if !cmp.Equal(v, tt.want) || state != tt.wantState {
+			t.Errorf("GetWithState() after %v = %v, %v, want %v, %v", tt.after, v, state, tt.want, tt.wantState)
+		}
+	}
+	if s.Exists("test:a") {
+		t.Error("want expired entries to be removed by redis")
+	}
+}
+
+func TestRedisCache_Delete(t *testing.T) {
+	c, s := newTestRedis(t, &fakeClock{now: time.Unix(0, 0)})
+	c.Put("a", &testPage{})
+	c.Put("b", &testPage{})
+	s.Set("other", "untouched")
+	if !c.Delete("a") || c.Delete("a") {
+		t.Error("Delete() want true for the first and false for the second call")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("Delete() removed another entry")
+	}
+	c.Put("c", &testPage{})
+	if n := c.DeleteFunc(func(k string) bool { return k == "c" }); n != 1 {
+		t.Errorf("DeleteFunc() = %d, want 1", n)
+	}
+	if keys := c.Keys(); !cmp.Equal(keys, []string{"b"}) {
+		t.Errorf("Keys() = %v, want the keys without prefix", keys)
+	}
+	c.Purge()
+	if _, ok := c.Get("b"); ok {
+		t.Error("Purge() kept an entry")
+	}
+	if !s.Exists("other") {
+		t.Error("Purge() removed a key without the prefix of the cache")
+	}
+}
+
+func TestRedisCache_unavailable(t *testing.T) {
+	c, s := newTestRedis(t, &fakeClock{now: time.Unix(0, 0)})
+	s.Close()
+	c.Put("a", &testPage{})
+	if v, state := c.GetWithState("a"); v != nil || state != Miss {
+		t.Errorf("GetWithState() = %v, %v, want a miss while redis is down", v, state)
+	}
+}
+
+func TestRedisCache_Stats(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, _ := newTestRedis(t, clock)
+	c.Put("a", &testPage{})
+	c.Get("a")
+	c.Get("missing")
+	clock.Add(time.Minute + time.Second)
+	c.GetWithState("a")
+	want := Stats{Hits: 1, StaleHits: 1, Misses: 1, Entries: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
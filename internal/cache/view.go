@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// View is a typed view of the entries of a cache under a namespace. Views of different
+// types sharing a cache never see each other's entries.
+type View[V any] struct {
+	c  Cache[string, any]
+	ns string
+}
+
+// NewView returns the view of the entries of c whose keys are prefixed with namespace.
+func NewView[V any](c Cache[string, any], namespace string) *View[V] {
+	return &View[V]{c: c, ns: namespace}
+}
+
+func (v *View[V]) key(k string) string {
+	return v.ns + k
+}
+
+// Get returns the value of k as long as it is fresh.
+func (v *View[V]) Get(k string) (V, bool) {
+	val, state := v.GetWithState(k)
+	return val, state == Fresh
+}
+
+// GetWithState returns the value of k, including stale values, along with its state.
+// A value of another type than V is reported as a miss.
+func (v *View[V]) GetWithState(k string) (V, State) {
+	cached, state := v.c.GetWithState(v.key(k))
+	val, ok := cached.(V)
+	if !ok {
+		var zero V
+		return zero, Miss
+	}
+	return val, state
+}
+
+// Put adds or replaces the value of k, which is fresh for the default TTL of the cache.
+func (v *View[V]) Put(k string, val V) {
+	v.c.Put(v.key(k), val)
+}
+
+// PutWithTTL adds or replaces the value of k, which is fresh for ttl.
+func (v *View[V]) PutWithTTL(k string, val V, ttl time.Duration) {
+	v.c.PutWithTTL(v.key(k), val, ttl)
+}
+
+// Delete removes the entry of k and reports whether there was one.
+func (v *View[V]) Delete(k string) bool {
+	return v.c.Delete(v.key(k))
+}
+
+// DeletePrefix removes the entries whose key starts with prefix and returns how many there were.
+func (v *View[V]) DeletePrefix(prefix string) int {
+	return v.c.DeleteFunc(func(k string) bool {
+		return strings.HasPrefix(k, v.key(prefix))
+	})
+}
+
+// Keys lists the keys of the view without its namespace.
+func (v *View[V]) Keys() []string {
+	var keys []string
+	for _, k := range v.c.Keys() {
+		if strings.HasPrefix(k, v.ns) {
+			keys = append(keys, strings.TrimPrefix(k, v.ns))
+		}
+	}
+	return keys
+}
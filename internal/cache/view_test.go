@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestView(t *testing.T) {
+	c := NewWithConfig(Config[string, any]{TTL: time.Minute, JanitorInterval: -1})
+	defer c.Close()
+	names := NewView[[]string](c, "names:")
+	ids := NewView[[]int64](c, "ids:")
+	names.Put("me", []string{"blog"})
+	ids.Put("me", []int64{1})
+	if v, ok := names.Get("me"); !ok || len(v) != 1 || v[0] != "blog" {
+		t.Errorf("Get() = %v, %v, want the names", v, ok)
+	}
+	if v, ok := ids.Get("me"); !ok || len(v) != 1 || v[0] != 1 {
+		t.Errorf("Get() = %v, %v, want the ids", v, ok)
+	}
+	// A value of another type under the namespace of a view is never handed out.
+	c.Put("names:other", 42)
+	if v, state := names.GetWithState("other"); v != nil || state != Miss {
+		t.Errorf("GetWithState() = %v, %v, want a miss for a value of another type", v, state)
+	}
+	if !ids.Delete("me") {
+		t.Error("Delete() = false, want true")
+	}
+	if _, ok := names.Get("me"); !ok {
+		t.Error("Delete() removed the entry of another view")
+	}
+}
+
+func TestView_DeletePrefix(t *testing.T) {
+	c := NewWithConfig(Config[string, any]{TTL: time.Minute, JanitorInterval: -1})
+	defer c.Close()
+	repos := NewView[int](c, "repositories:")
+	commits := NewView[int](c, "commits:")
+	repos.Put("me/repositories", 1)
+	repos.Put("you/repositories", 2)
+	commits.Put("me/blog/commits", 3)
+	if n := repos.DeletePrefix("me/"); n != 1 {
+		t.Errorf("DeletePrefix() = %d, want 1", n)
+	}
+	if keys := repos.Keys(); len(keys) != 1 || keys[0] != "you/repositories" {
+		t.Errorf("Keys() = %v, want the remaining key without namespace", keys)
+	}
+	if _, ok := commits.Get("me/blog/commits"); !ok {
+		t.Error("DeletePrefix() removed the entry of another view")
+	}
+}
@@ -0,0 +1,275 @@
+package gh
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBaseURL = "https://api.github.com/"
+	oauthTokenURL  = "https://github.com/login/oauth/access_token"
+	// expiryDelta is how long before the actual expiry a token is considered stale.
+	expiryDelta = time.Minute
+	// tokenTimeout bounds fetching a token. Requests wait for a token while holding up every other
+	// request needing one, a hanging token endpoint must not hang them forever.
+	tokenTimeout = 30 * time.Second
+)
+
+// tokenClient fetches tokens when no client is configured.
+var tokenClient = &http.Client{Timeout: tokenTimeout}
+
+// Token is an access token used to authenticate against the github api.
+type Token struct {
+	Value string
+	// Expiry is the zero value for tokens which never expire.
+	Expiry time.Time
+}
+
+// Valid reports whether the token is set and not about to expire.
+func (t *Token) Valid() bool {
+	if t == nil || t.Value == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.Expiry)
+}
+
+// TokenSource supplies tokens to authenticate requests. Fetching a token is given up once ctx is done.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+type staticTokenSource struct {
+	token *Token
+}
+
+// StaticTokenSource returns a TokenSource which always returns the same non expiring token,
+// e.g. a personal access token.
+func StaticTokenSource(token string) TokenSource {
+	return &staticTokenSource{token: &Token{Value: token}}
+}
+
+func (s *staticTokenSource) Token(ctx context.Context) (*Token, error) {
+	return s.token, nil
+}
+
+type reuseTokenSource struct {
+	mu  sync.Mutex
+	t   *Token
+	src TokenSource
+}
+
+// ReuseTokenSource returns a TokenSource which returns t as long as it is valid and
+// fetches a new token from src once it expires.
+func ReuseTokenSource(t *Token, src TokenSource) TokenSource {
+	return &reuseTokenSource{t: t, src: src}
+}
+
+func (s *reuseTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t.Valid() {
+		return s.t, nil
+	}
+	t, err := s.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.t = t
+	return t, nil
+}
+
+// InstallationTokenSource exchanges a GitHub App JWT for an installation access token.
+type InstallationTokenSource struct {
+	AppID          int64
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+	// BaseURL defaults to https://api.github.com/.
+	BaseURL string
+	// Client defaults to a client giving up after 30 seconds.
+	Client *http.Client
+}
+
+// Token fetches a new installation access token.
+func (s *InstallationTokenSource) Token(ctx context.Context) (*Token, error) {
+	jwt, err := s.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	u := fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(baseURL, "/"), s.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := doTokenRequest(s.Client, req, &body); err != nil {
+		return nil, errors.Wrap(err, "could not fetch installation token")
+	}
+	return &Token{Value: body.Token, Expiry: body.ExpiresAt}, nil
+}
+
+// jwt creates the RS256 signed token which authenticates as the app itself.
+func (s *InstallationTokenSource) jwt(now time.Time) (string, error) {
+	if s.PrivateKey == nil {
+		return "", errors.New("missing github app private key")
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// Backdated to allow for clock drift between us and github.
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(s.AppID, 10),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS1 or PKCS8 RSA private key.
+func ParsePrivateKey(pemKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid github app private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid github app private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// OAuthTokenSource refreshes expiring OAuth user access tokens.
+type OAuthTokenSource struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// TokenURL defaults to https://github.com/login/oauth/access_token.
+	TokenURL string
+	// Client defaults to a client giving up after 30 seconds.
+	Client *http.Client
+
+	mu sync.Mutex
+}
+
+// Token exchanges the refresh token for a new access token.
+func (s *OAuthTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.RefreshToken == "" {
+		return nil, errors.New("oauth token expired and no refresh token is configured")
+	}
+	tokenURL := s.TokenURL
+	if tokenURL == "" {
+		tokenURL = oauthTokenURL
+	}
+	form := url.Values{
+		"client_id":     {s.ClientID},
+		"client_secret": {s.ClientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.RefreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Error        string `json:"error"`
+		Description  string `json:"error_description"`
+	}
+	if err := doTokenRequest(s.Client, req, &body); err != nil {
+		return nil, errors.Wrap(err, "could not refresh oauth token")
+	}
+	if body.Error != "" {
+		return nil, errors.Errorf("could not refresh oauth token: %s: %s", body.Error, body.Description)
+	}
+	// github rotates the refresh token on every use.
+	if body.RefreshToken != "" {
+		s.RefreshToken = body.RefreshToken
+	}
+	t := &Token{Value: body.AccessToken}
+	if body.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+func doTokenRequest(client *http.Client, req *http.Request, v interface{}) error {
+	if client == nil {
+		client = tokenClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Transport is a http.RoundTripper which authenticates requests with tokens from Source.
+type Transport struct {
+	Source TokenSource
+	// Base defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// RoundTrip authorizes and sends the request.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "token "+token.Value)
+	return t.base().RoundTrip(r)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
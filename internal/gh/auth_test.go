@@ -0,0 +1,223 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func jsonResponse(status int, v interface{}) *http.Response {
+	body, _ := json.Marshal(v)
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Header:     make(http.Header),
+	}
+}
+
+type countingTokenSource struct {
+	calls int
+	ttl   time.Duration
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.calls++
+	return &Token{Value: "refreshed", Expiry: time.Now().Add(s.ttl)}, nil
+}
+
+func TestNew_Authentication(t *testing.T) {
+	tests := map[string]struct {
+		cfg  Config
+		want string
+	}{
+		"unauthenticated": {
+			cfg: Config{},
+		},
+		"personal-access-token": {
+			cfg:  Config{TokenSource: StaticTokenSource("pat")},
+			want: "token pat",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got string
+			fakeHttpClient := NewFakeHttpClient(func(req *http.Request) *http.Response {
+				got = req.Header.Get("Authorization")
+				return jsonResponse(http.StatusOK, []*github.Repository{})
+			})
+			g := New(fakeHttpClient, &log.Logger{}, tt.cfg)
+			if _, _, err := g.ListRepositories(context.Background(), "me", &github.RepositoryListOptions{}); err != nil {
+				t.Fatalf("Client.ListRepositories() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReuseTokenSource(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		src := &countingTokenSource{ttl: time.Hour}
+		ts := ReuseTokenSource(&Token{Value: "initial"}, src)
+		tok, err := ts.Token(context.Background())
+		if err != nil || tok.Value != "initial" || src.calls != 0 {
+			t.Errorf("Token() = %v, %v with %d refreshes, want initial token", tok, err, src.calls)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		src := &countingTokenSource{ttl: time.Hour}
+		ts := ReuseTokenSource(&Token{Value: "initial", Expiry: time.Now().Add(-time.Second)}, src)
+		for i := 0; i < 2; i++ {
+			tok, err := ts.Token(context.Background())
+			if err != nil || tok.Value != "refreshed" {
+				t.Errorf("Token() = %v, %v, want refreshed token", tok, err)
+			}
+		}
+		if src.calls != 1 {
+			t.Errorf("refreshes = %d, want 1", src.calls)
+		}
+	})
+}
+
+func TestInstallationTokenSource_Token(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var gotPath, gotAuth string
+	ts := &InstallationTokenSource{
+		AppID:          1,
+		InstallationID: 42,
+		PrivateKey:     key,
+		Client: NewFakeHttpClient(func(req *http.Request) *http.Response {
+			gotPath, gotAuth = req.URL.Path, req.Header.Get("Authorization")
+			return jsonResponse(http.StatusCreated, map[string]interface{}{"token": "installation", "expires_at": expiry})
+		}),
+	}
+	tok, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tok.Value != "installation" || !tok.Expiry.Equal(expiry) {
+		t.Errorf("Token() = %v, want installation token expiring at %v", tok, expiry)
+	}
+	if gotPath != "/app/installations/42/access_tokens" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "Bearer ") || strings.Count(gotAuth, ".") != 2 {
+		t.Errorf("Authorization = %q, want a bearer JWT", gotAuth)
+	}
+}
+
+func TestOAuthTokenSource_Token(t *testing.T) {
+	t.Run("refresh", func(t *testing.T) {
+		var gotRefresh string
+		ts := &OAuthTokenSource{
+			ClientID:     "id",
+			ClientSecret: "secret",
+			RefreshToken: "r1",
+			Client: NewFakeHttpClient(func(req *http.Request) *http.Response {
+				req.ParseForm()
+				gotRefresh = req.PostForm.Get("refresh_token")
+				return jsonResponse(http.StatusOK, map[string]interface{}{"access_token": "access", "expires_in": 28800, "refresh_token": "r2"})
+			}),
+		}
+		tok, err := ts.Token(context.Background())
+		if err != nil || tok.Value != "access" || !tok.Valid() {
+			t.Errorf("Token() = %v, %v, want valid access token", tok, err)
+		}
+		if gotRefresh != "r1" || ts.RefreshToken != "r2" {
+			t.Errorf("refresh token sent %q, stored %q, want r1 and r2", gotRefresh, ts.RefreshToken)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ts := &OAuthTokenSource{
+			RefreshToken: "r1",
+			Client: NewFakeHttpClient(func(req *http.Request) *http.Response {
+				return jsonResponse(http.StatusOK, map[string]interface{}{"error": "bad_refresh_token"})
+			}),
+		}
+		if _, err := ts.Token(context.Background()); err == nil {
+			t.Error("Token() error = nil, want error")
+		}
+	})
+	t.Run("hanging", func(t *testing.T) {
+		ts := &OAuthTokenSource{
+			RefreshToken: "r1",
+			Client: NewFakeHttpClient(func(req *http.Request) *http.Response {
+				<-req.Context().Done()
+				return nil
+			}),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := ts.Token(ctx); err == nil {
+			t.Error("Token() error = nil, want the request to be given up")
+		}
+	})
+}
+
+func TestConfigFromEnv_OAuth(t *testing.T) {
+	tests := map[string]struct {
+		expiresAt string
+		want      string
+		wantErr   bool
+	}{
+		"expired": {
+			expiresAt: time.Now().Add(-time.Minute).Format(time.RFC3339),
+			want:      "refreshed",
+		},
+		"about-to-expire": {
+			expiresAt: time.Now().Add(expiryDelta / 2).Format(time.RFC3339),
+			want:      "refreshed",
+		},
+		"valid": {
+			expiresAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+			want:      "initial",
+		},
+		"no-expiry": {
+			want: "initial",
+		},
+		"invalid-expiry": {
+			expiresAt: "tomorrow",
+			wantErr:   true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GITHUB_APP_ID", "")
+			t.Setenv("GITHUB_OAUTH_TOKEN", "initial")
+			t.Setenv("GITHUB_OAUTH_TOKEN_EXPIRES_AT", tt.expiresAt)
+			t.Setenv("GITHUB_OAUTH_REFRESH_TOKEN", "r1")
+			cfg, err := ConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			src := cfg.TokenSource.(*reuseTokenSource).src.(*OAuthTokenSource)
+			src.Client = NewFakeHttpClient(func(req *http.Request) *http.Response {
+				return jsonResponse(http.StatusOK, map[string]interface{}{"access_token": "refreshed", "expires_in": 28800, "refresh_token": "r2"})
+			})
+			tok, err := cfg.TokenSource.Token(context.Background())
+			if err != nil || tok.Value != tt.want {
+				t.Errorf("Token() = %v, %v, want %s token", tok, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package gh
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBreakerThreshold   = 5
+	defaultBreakerOpenTimeout = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned while the circuit breaker keeps requests from reaching github.
+var ErrCircuitOpen = errors.New("github is unavailable, circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every request right away.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through to find out whether github recovered.
+	BreakerHalfOpen
+)
+
+var breakerStateNames = map[BreakerState]string{
+	BreakerClosed:   "closed",
+	BreakerOpen:     "open",
+	BreakerHalfOpen: "half_open",
+}
+
+func (s BreakerState) String() string {
+	return breakerStateNames[s]
+}
+
+// MarshalText renders the state by its name.
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// BreakerConfig configures a Breaker.
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failures opening the breaker. Defaults to 5.
+	Threshold int
+	// OpenTimeout is how long the breaker stays open before probing github. Defaults to 30s.
+	OpenTimeout time.Duration
+	// OnStateChange is called whenever the breaker changes its state.
+	OnStateChange func(from, to BreakerState)
+	// Now is the clock of the breaker. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Breaker is a Fetcher which stops calling github after consecutive failures. Only timeouts and
+// github being unavailable count as failures, github answering with an error proves it is up.
+type Breaker struct {
+	next Fetcher
+	cfg  BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker wraps next in a circuit breaker.
+func NewBreaker(next Fetcher, cfg BreakerConfig) *Breaker {
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultBreakerThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Breaker{next: next, cfg: cfg}
+}
+
+// State returns the state of the breaker. An open breaker whose timeout passed is reported
+// as half-open, the next request probes github.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.cfg.Now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may go through and whether it is the probe of a half-open breaker.
+func (b *Breaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerClosed:
+		return false, nil
+	case BreakerOpen:
+		if b.cfg.Now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+	}
+	if b.probing {
+		return false, ErrCircuitOpen
+	}
+	b.probing = true
+	return true, nil
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *Breaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if !isOutage(err) {
+		b.failures = 0
+		if probe || b.state == BreakerHalfOpen {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if probe || b.failures >= b.cfg.Threshold {
+		b.openedAt = b.cfg.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *Breaker) setState(to BreakerState) {
+	from := b.state
+	b.state = to
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
+
+// isOutage reports whether err means github could not answer.
+func isOutage(err error) bool {
+	switch Classify(err) {
+	case KindUnavailable, KindTimeout:
+		return true
+	}
+	return false
+}
+
+// ListRepositories lists the public repositories of a user unless the breaker is open.
+func (b *Breaker) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, *Pagination, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, nil, err
+	}
+	repos, pagination, err := b.next.ListRepositories(ctx, username, opt)
+	b.record(probe, err)
+	return repos, pagination, err
+}
+
+// ListAllRepositories streams every page of the public repositories of a user unless the breaker is open.
+func (b *Breaker) ListAllRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) <-chan RepositoryPage {
+	pages := make(chan RepositoryPage, 1)
+	probe, err := b.allow()
+	if err != nil {
+		pages <- RepositoryPage{Err: err}
+		close(pages)
+		return pages
+	}
+	upstream := b.next.ListAllRepositories(ctx, username, opt)
+	go func() {
+		defer close(pages)
+		var last error
+		defer func() { b.record(probe, last) }()
+		for page := range upstream {
+			last = page.Err
+			select {
+			case pages <- page:
+			case <-ctx.Done():
+				// Drain the upstream channel so that its producer can return.
+				for range upstream {
+				}
+				return
+			}
+		}
+	}()
+	return pages
+}
+
+// ListCommits lists the commits of a repository unless the breaker is open.
+func (b *Breaker) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, *Pagination, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, nil, err
+	}
+	commits, pagination, err := b.next.ListCommits(ctx, username, repoName, opt)
+	b.record(probe, err)
+	return commits, pagination, err
+}
+
+// ListOrgRepositories lists the repositories of an organization unless the breaker is open.
+func (b *Breaker) ListOrgRepositories(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*Repository, *Pagination, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, nil, err
+	}
+	repos, pagination, err := b.next.ListOrgRepositories(ctx, org, opt)
+	b.record(probe, err)
+	return repos, pagination, err
+}
+
+// GetOwnerType tells whether name is a user or an organization unless the breaker is open.
+func (b *Breaker) GetOwnerType(ctx context.Context, name string) (OwnerType, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return "", err
+	}
+	t, err := b.next.GetOwnerType(ctx, name)
+	b.record(probe, err)
+	return t, err
+}
@@ -0,0 +1,152 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// stubFetcher answers every call with err.
+type stubFetcher struct {
+	err   error
+	calls int
+}
+
+func (s *stubFetcher) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, *Pagination, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	return []*Repository{{Name: "repo"}}, &Pagination{}, nil
+}
+
+func (s *stubFetcher) ListAllRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) <-chan RepositoryPage {
+	s.calls++
+	pages := make(chan RepositoryPage, 1)
+	pages <- RepositoryPage{Err: s.err}
+	close(pages)
+	return pages
+}
+
+func (s *stubFetcher) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, *Pagination, error) {
+	s.calls++
+	return nil, &Pagination{}, s.err
+}
+
+func (s *stubFetcher) ListOrgRepositories(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*Repository, *Pagination, error) {
+	s.calls++
+	return nil, &Pagination{}, s.err
+}
+
+func (s *stubFetcher) GetOwnerType(ctx context.Context, name string) (OwnerType, error) {
+	s.calls++
+	return OwnerUser, s.err
+}
+
+func TestBreaker(t *testing.T) {
+	unavailable := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}
+	now := time.Unix(0, 0)
+	var transitions []string
+	next := &stubFetcher{err: unavailable}
+	b := NewBreaker(next, BreakerConfig{
+		Threshold:   2,
+		OpenTimeout: time.Minute,
+		Now:         func() time.Time { return now },
+		OnStateChange: func(from, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := b.ListRepositories(ctx, "user", nil); err != unavailable {
+			t.Fatalf("call %d: got err %v, want the upstream error", i, err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("got state %s, want open", b.State())
+	}
+	if _, _, err := b.ListCommits(ctx, "user", "repo", nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got err %v, want ErrCircuitOpen", err)
+	}
+	if Classify(ErrCircuitOpen) != KindUnavailable {
+		t.Errorf("ErrCircuitOpen classified as %s", Classify(ErrCircuitOpen))
+	}
+	if next.calls != 2 {
+		t.Errorf("github was called %d times while open", next.calls-2)
+	}
+
+	// A failing probe opens the breaker for another timeout.
+	now = now.Add(time.Minute)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("got state %s, want half_open", b.State())
+	}
+	b.ListRepositories(ctx, "user", nil)
+	if b.State() != BreakerOpen {
+		t.Fatalf("got state %s after a failed probe, want open", b.State())
+	}
+
+	// Github answering, even with an error, closes the breaker.
+	now = now.Add(time.Minute)
+	next.err = notFound
+	if _, _, err := b.ListRepositories(ctx, "user", nil); err != notFound {
+		t.Fatalf("got err %v, want the upstream error", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("got state %s after a successful probe, want closed", b.State())
+	}
+	want := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("got transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("got transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreaker_singleProbe(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBreaker(&stubFetcher{}, BreakerConfig{Threshold: 1, Now: func() time.Time { return now }})
+	b.record(false, context.DeadlineExceeded)
+	now = now.Add(defaultBreakerOpenTimeout)
+
+	probe, err := b.allow()
+	if !probe || err != nil {
+		t.Fatalf("got probe %v err %v, want the first request to probe", probe, err)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got err %v while probing, want ErrCircuitOpen", err)
+	}
+	b.record(probe, nil)
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("got err %v after the probe succeeded", err)
+	}
+}
+
+func TestBreaker_ListAllRepositories(t *testing.T) {
+	next := &stubFetcher{err: context.DeadlineExceeded}
+	b := NewBreaker(next, BreakerConfig{Threshold: 1})
+	for page := range b.ListAllRepositories(context.Background(), "user", nil) {
+		if page.Err != context.DeadlineExceeded {
+			t.Fatalf("got err %v, want the upstream error", page.Err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("got state %s, want open", b.State())
+	}
+	for page := range b.ListAllRepositories(context.Background(), "user", nil) {
+		if !errors.Is(page.Err, ErrCircuitOpen) {
+			t.Fatalf("got err %v, want ErrCircuitOpen", page.Err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("got %d calls, want 1", next.calls)
+	}
+}
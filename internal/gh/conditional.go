@@ -0,0 +1,133 @@
+package gh
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultResponseCacheSize bounds the in-memory ResponseCache.
+const defaultResponseCacheSize = 1000
+
+// CachedResponse is a github response which can be revalidated with a conditional request.
+type CachedResponse struct {
+	URL          string `gorm:"primaryKey"`
+	ETag         string
+	LastModified string
+	// Link holds the pagination links of the response.
+	Link      string
+	Body      []byte
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+// ResponseCache stores github responses by request URL.
+type ResponseCache interface {
+	// GetResponse returns nil when nothing is stored for url.
+	GetResponse(url string) (*CachedResponse, error)
+	PutResponse(r *CachedResponse) error
+}
+
+type memoryResponseCache struct {
+	mu   sync.Mutex
+	m    map[string]*CachedResponse
+	size int
+}
+
+// NewMemoryResponseCache returns a ResponseCache holding at most size responses in memory.
+func NewMemoryResponseCache(size int) ResponseCache {
+	return &memoryResponseCache{m: make(map[string]*CachedResponse), size: size}
+}
+
+func (c *memoryResponseCache) GetResponse(url string) (*CachedResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[url], nil
+}
+
+func (c *memoryResponseCache) PutResponse(r *CachedResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[r.URL]; !ok && len(c.m) >= c.size {
+		// Dropping an arbitrary response only costs a full request later on.
+		for k := range c.m {
+			delete(c.m, k)
+			break
+		}
+	}
+	c.m[r.URL] = r
+	return nil
+}
+
+// conditionalTransport revalidates GET requests with If-None-Match and If-Modified-Since.
+// Github does not count 304 Not Modified responses against the rate limit, the
+// previously stored payload is served instead.
+type conditionalTransport struct {
+	cache ResponseCache
+	base  http.RoundTripper
+	log   *log.Logger
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	key := req.URL.String()
+	// A failing cache must not fail the request, it only makes it unconditional.
+	cached, _ := t.cache.GetResponse(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		resp.Body.Close()
+		return notModified(resp, cached), nil
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err := t.cache.PutResponse(&CachedResponse{
+			URL:          key,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Link:         resp.Header.Get("Link"),
+			Body:         body,
+			UpdatedAt:    time.Now(),
+		}); err != nil {
+			// Like a failing read, the next request is only unconditional.
+			t.log.Println("could not store github response", key, err.Error())
+		}
+	}
+	return resp, nil
+}
+
+// notModified turns a 304 response into the 200 response it revalidated. The rate limit
+// headers of the 304 response are kept.
+func notModified(resp *http.Response, cached *CachedResponse) *http.Response {
+	r := *resp
+	r.StatusCode = http.StatusOK
+	r.Status = "200 OK"
+	r.Header = resp.Header.Clone()
+	if cached.Link != "" {
+		r.Header.Set("Link", cached.Link)
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+	r.ContentLength = int64(len(cached.Body))
+	return &r
+}
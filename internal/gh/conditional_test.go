@@ -0,0 +1,85 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+)
+
+func TestClient_ConditionalRequests(t *testing.T) {
+	repo := Repository{ID: 1, Name: "blog", NodeID: "1", Owner: "me"}
+	var gotIfNoneMatch []string
+	fakeHttpClient := NewFakeHttpClient(func(req *http.Request) *http.Response {
+		gotIfNoneMatch = append(gotIfNoneMatch, req.Header.Get("If-None-Match"))
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			resp := jsonResponse(http.StatusNotModified, nil)
+			resp.Header.Set("X-RateLimit-Limit", "60")
+			resp.Header.Set("X-RateLimit-Remaining", "59")
+			return resp
+		}
+		resp := jsonResponse(http.StatusOK, mapToRepository(repo))
+		resp.Header.Set("ETag", `"v1"`)
+		return resp
+	})
+	responses := NewMemoryResponseCache(10)
+	g := New(fakeHttpClient, &log.Logger{}, Config{ResponseCache: responses})
+	for i := 0; i < 2; i++ {
+		got, _, err := g.ListRepositories(context.Background(), "me", &github.RepositoryListOptions{})
+		if err != nil {
+			t.Fatalf("Client.ListRepositories() error = %v", err)
+		}
+		if !cmp.Equal(got, []*Repository{&repo}) {
+			t.Errorf("Client.ListRepositories() = %v, want %v", got, []*Repository{&repo})
+		}
+	}
+	if want := []string{"", `"v1"`}; !cmp.Equal(gotIfNoneMatch, want) {
+		t.Errorf("If-None-Match = %q, want %q", gotIfNoneMatch, want)
+	}
+	if q := g.Quota(); q.Remaining != 59 {
+		t.Errorf("Quota() = %+v, want the rate limit of the 304 response", q)
+	}
+}
+
+func TestMemoryResponseCache_PutResponse(t *testing.T) {
+	c := NewMemoryResponseCache(1)
+	c.PutResponse(&CachedResponse{URL: "a"})
+	c.PutResponse(&CachedResponse{URL: "b"})
+	a, _ := c.GetResponse("a")
+	b, _ := c.GetResponse("b")
+	if a != nil || b == nil {
+		t.Errorf("GetResponse() = %v, %v, want only the latest response", a, b)
+	}
+}
+
+type failingResponseCache struct{}
+
+func (failingResponseCache) GetResponse(url string) (*CachedResponse, error) {
+	return nil, errors.New("db down")
+}
+
+func (failingResponseCache) PutResponse(r *CachedResponse) error {
+	return errors.New("db down")
+}
+
+func TestClient_ConditionalRequestsFailingCache(t *testing.T) {
+	fakeHttpClient := NewFakeHttpClient(func(req *http.Request) *http.Response {
+		resp := jsonResponse(http.StatusOK, []*github.Repository{})
+		resp.Header.Set("ETag", `"v1"`)
+		return resp
+	})
+	var logged bytes.Buffer
+	g := New(fakeHttpClient, log.New(&logged, "", 0), Config{ResponseCache: failingResponseCache{}})
+	if _, _, err := g.ListRepositories(context.Background(), "me", &github.RepositoryListOptions{}); err != nil {
+		t.Fatalf("Client.ListRepositories() error = %v", err)
+	}
+	if !strings.Contains(logged.String(), "could not store github response") {
+		t.Errorf("log = %q, want the failed write", logged.String())
+	}
+}
@@ -0,0 +1,107 @@
+package gh
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxPages caps ListAllRepositories when no maximum is configured.
+const defaultMaxPages = 10
+
+// Config holds the github client configuration.
+type Config struct {
+	// TokenSource authenticates outgoing requests. Requests are unauthenticated when nil.
+	TokenSource TokenSource
+	// RateLimitWait is the longest a request blocks waiting for the rate limit to reset.
+	// Requests fail fast with a RateLimitError when the wait would be longer.
+	RateLimitWait time.Duration
+	// MaxPages caps the number of pages ListAllRepositories fetches. Defaults to 10.
+	MaxPages int
+	// ResponseCache keeps the github responses used for conditional requests. Defaults to an in-memory cache.
+	ResponseCache ResponseCache
+	// Retry configures how requests failing transiently are retried.
+	Retry RetryPolicy
+}
+
+// ConfigFromEnv builds the client configuration from the environment.
+// A GitHub App installation takes precedence over an OAuth token, which takes precedence over a personal access token.
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	if wait := os.Getenv("GITHUB_RATE_LIMIT_WAIT"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			return cfg, errors.Wrap(err, "invalid GITHUB_RATE_LIMIT_WAIT")
+		}
+		cfg.RateLimitWait = d
+	}
+	if maxPages := os.Getenv("GITHUB_MAX_PAGES"); maxPages != "" {
+		n, err := strconv.Atoi(maxPages)
+		if err != nil {
+			return cfg, errors.Wrap(err, "invalid GITHUB_MAX_PAGES")
+		}
+		cfg.MaxPages = n
+	}
+	if attempts := os.Getenv("GITHUB_RETRY_ATTEMPTS"); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil {
+			return cfg, errors.Wrap(err, "invalid GITHUB_RETRY_ATTEMPTS")
+		}
+		cfg.Retry.MaxAttempts = n
+	}
+	switch {
+	case os.Getenv("GITHUB_APP_ID") != "":
+		ts, err := installationTokenSourceFromEnv()
+		if err != nil {
+			return cfg, err
+		}
+		cfg.TokenSource = ts
+	case os.Getenv("GITHUB_OAUTH_TOKEN") != "" || os.Getenv("GITHUB_OAUTH_REFRESH_TOKEN") != "":
+		token := &Token{Value: os.Getenv("GITHUB_OAUTH_TOKEN")}
+		// Without an expiry the token is used until the service restarts, it is never refreshed.
+		if expiresAt := os.Getenv("GITHUB_OAUTH_TOKEN_EXPIRES_AT"); expiresAt != "" {
+			expiry, err := time.Parse(time.RFC3339, expiresAt)
+			if err != nil {
+				return cfg, errors.Wrap(err, "invalid GITHUB_OAUTH_TOKEN_EXPIRES_AT")
+			}
+			token.Expiry = expiry
+		}
+		cfg.TokenSource = ReuseTokenSource(token, &OAuthTokenSource{
+			ClientID:     os.Getenv("GITHUB_OAUTH_CLIENT_ID"),
+			ClientSecret: os.Getenv("GITHUB_OAUTH_CLIENT_SECRET"),
+			RefreshToken: os.Getenv("GITHUB_OAUTH_REFRESH_TOKEN"),
+		})
+	case os.Getenv("GITHUB_TOKEN") != "":
+		cfg.TokenSource = StaticTokenSource(os.Getenv("GITHUB_TOKEN"))
+	}
+	return cfg, nil
+}
+
+func installationTokenSourceFromEnv() (TokenSource, error) {
+	appID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_ID"), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GITHUB_APP_ID")
+	}
+	installationID, err := strconv.ParseInt(os.Getenv("GITHUB_APP_INSTALLATION_ID"), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GITHUB_APP_INSTALLATION_ID")
+	}
+	pemKey := []byte(os.Getenv("GITHUB_APP_PRIVATE_KEY"))
+	if path := os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH"); len(pemKey) == 0 && path != "" {
+		if pemKey, err = ioutil.ReadFile(path); err != nil {
+			return nil, errors.Wrap(err, "could not read github app private key")
+		}
+	}
+	key, err := ParsePrivateKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+	return ReuseTokenSource(nil, &InstallationTokenSource{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     key,
+	}), nil
+}
@@ -0,0 +1,92 @@
+package gh
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+)
+
+// Kind classifies the errors returned by the client.
+type Kind int
+
+const (
+	// KindUnknown errors could not be classified.
+	KindUnknown Kind = iota
+	// KindNotFound errors are returned for users or repositories github does not know about.
+	KindNotFound
+	// KindUnauthorized errors are returned when the credentials are missing, invalid or lack permissions.
+	KindUnauthorized
+	// KindRateLimited errors are returned once the quota is exhausted, see RateLimitError.
+	KindRateLimited
+	// KindUnavailable errors are returned when github could not be reached or failed to answer.
+	KindUnavailable
+	// KindTimeout errors are returned when github did not answer in time.
+	KindTimeout
+	// KindValidation errors are returned when github rejected the parameters of a request.
+	KindValidation
+)
+
+var kindNames = map[Kind]string{
+	KindUnknown:      "unknown",
+	KindNotFound:     "not_found",
+	KindUnauthorized: "unauthorized",
+	KindRateLimited:  "rate_limited",
+	KindUnavailable:  "upstream_unavailable",
+	KindTimeout:      "timeout",
+	KindValidation:   "validation",
+}
+
+// String returns the machine readable name of the kind.
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Classify returns the kind of an error returned by the client.
+func Classify(err error) Kind {
+	if err == nil {
+		return KindUnknown
+	}
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		return KindRateLimited
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return KindUnavailable
+	}
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		return classifyStatus(errResp.Response.StatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return KindTimeout
+		}
+		return KindUnavailable
+	}
+	return KindUnknown
+}
+
+func classifyStatus(status int) Kind {
+	switch {
+	case status == http.StatusNotFound, status == http.StatusGone:
+		return KindNotFound
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return KindUnauthorized
+	case status == http.StatusTooManyRequests:
+		return KindRateLimited
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return KindValidation
+	case status == http.StatusGatewayTimeout:
+		return KindTimeout
+	case status >= 500:
+		return KindUnavailable
+	}
+	return KindUnknown
+}
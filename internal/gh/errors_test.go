@@ -0,0 +1,49 @@
+package gh
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+)
+
+func errorResponse(status int) error {
+	return &github.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{Method: "GET", URL: &url.URL{}}}}
+}
+
+func TestClassify(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want Kind
+	}{
+		"nil":          {err: nil, want: KindUnknown},
+		"unknown":      {err: errors.New("boom"), want: KindUnknown},
+		"not-found":    {err: errorResponse(http.StatusNotFound), want: KindNotFound},
+		"unauthorized": {err: errorResponse(http.StatusUnauthorized), want: KindUnauthorized},
+		"forbidden":    {err: errorResponse(http.StatusForbidden), want: KindUnauthorized},
+		"rate-limited": {err: &RateLimitError{RetryAfter: time.Minute, Err: errorResponse(http.StatusForbidden)}, want: KindRateLimited},
+		"validation":   {err: errorResponse(http.StatusUnprocessableEntity), want: KindValidation},
+		"bad-gateway":  {err: errorResponse(http.StatusBadGateway), want: KindUnavailable},
+		"deadline":     {err: errors.Wrap(context.DeadlineExceeded, "listing"), want: KindTimeout},
+		"net-timeout": {
+			err:  &url.Error{Op: "Get", URL: "https://api.github.com", Err: &net.DNSError{IsTimeout: true}},
+			want: KindTimeout,
+		},
+		"connection-refused": {
+			err:  &url.Error{Op: "Get", URL: "https://api.github.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}},
+			want: KindUnavailable,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
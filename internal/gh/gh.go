@@ -7,115 +7,362 @@ import (
 	"time"
 
 	"github.com/google/go-github/v32/github"
+	"github.com/lib/pq"
 )
 
 // Client represents the gihub client which connects to the github api
 type Client struct {
-	client *github.Client
-	log    *log.Logger
+	client   *github.Client
+	log      *log.Logger
+	rate     *rateLimiter
+	maxPages int
 }
 
-// New initializes a github client.
-func New(client *http.Client, log *log.Logger) *Client {
+// New initializes a github client. Requests are authenticated with the token source in cfg
+// and revalidated against the responses in cfg.ResponseCache.
+func New(client *http.Client, log *log.Logger, cfg Config) *Client {
 	return &Client{
-		client: github.NewClient(client),
-		log:    log,
+		client:   github.NewClient(httpClient(client, log, cfg)),
+		log:      log,
+		rate:     newRateLimiter(cfg.RateLimitWait),
+		maxPages: cfg.MaxPages,
 	}
 }
 
-//Fetcher represents github fetch operations
+// httpClient returns a copy of client which sends conditional, authenticated requests and retries transient failures.
+func httpClient(client *http.Client, log *log.Logger, cfg Config) *http.Client {
+	c := http.Client{}
+	if client != nil {
+		c = *client
+	}
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	base = newRetryTransport(cfg.Retry, base)
+	if cfg.TokenSource != nil {
+		base = &Transport{Source: cfg.TokenSource, Base: base}
+	}
+	responses := cfg.ResponseCache
+	if responses == nil {
+		responses = NewMemoryResponseCache(defaultResponseCacheSize)
+	}
+	c.Transport = &conditionalTransport{cache: responses, base: base, log: log}
+	return &c
+}
+
+// Fetcher represents github fetch operations
 type Fetcher interface {
-	ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, error)
-	ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, error)
+	ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, *Pagination, error)
+	ListAllRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) <-chan RepositoryPage
+	ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, *Pagination, error)
+	ListOrgRepositories(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*Repository, *Pagination, error)
+	GetOwnerType(ctx context.Context, name string) (OwnerType, error)
+}
+
+// OwnerType tells whether an account is a user or an organization.
+type OwnerType string
+
+const (
+	OwnerUser         OwnerType = "User"
+	OwnerOrganization OwnerType = "Organization"
+)
+
+// Pagination locates a page within a listing. Pages start at 1, a zero NextPage or PrevPage
+// means there is no such page.
+type Pagination struct {
+	Page     int
+	PerPage  int
+	NextPage int
+	PrevPage int
+	LastPage int
+}
+
+func newPagination(opt github.ListOptions, resp *github.Response) *Pagination {
+	p := &Pagination{Page: opt.Page, PerPage: opt.PerPage}
+	if p.Page == 0 {
+		p.Page = 1
+	}
+	if resp != nil {
+		p.NextPage, p.PrevPage, p.LastPage = resp.NextPage, resp.PrevPage, resp.LastPage
+	}
+	// github omits the last link on the last page.
+	if p.NextPage == 0 {
+		p.LastPage = p.Page
+	}
+	return p
+}
+
+// RepositoryPage is a single page of repositories streamed by ListAllRepositories.
+type RepositoryPage struct {
+	Repositories []*Repository
+	Page         int
+	// Err is set on the last page sent when fetching failed.
+	Err error
 }
 
 // ListRepositories lists all the public repositories of a user
-func (g *Client) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, error) {
-	res, _, err := g.client.Repositories.List(ctx, username, opt)
+func (g *Client) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, *Pagination, error) {
+	if opt == nil {
+		opt = &github.RepositoryListOptions{}
+	}
+	repos, resp, err := g.listRepositories(ctx, username, opt)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return repos, newPagination(opt.ListOptions, resp), nil
+}
+
+func (g *Client) listRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*Repository, *github.Response, error) {
+	if err := g.rate.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	res, resp, err := g.client.Repositories.List(ctx, username, opt)
+	if err := g.rate.update(resp, err); err != nil {
+		return nil, resp, err
+	}
+	return mapFromRepository(res...), resp, nil
+}
+
+// ListOrgRepositories lists the repositories of an organization.
+func (g *Client) ListOrgRepositories(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*Repository, *Pagination, error) {
+	if opt == nil {
+		opt = &github.RepositoryListByOrgOptions{}
 	}
-	return mapFromRepository(res...), nil
+	if err := g.rate.wait(ctx); err != nil {
+		return nil, nil, err
+	}
+	res, resp, err := g.client.Repositories.ListByOrg(ctx, org, opt)
+	if err := g.rate.update(resp, err); err != nil {
+		return nil, nil, err
+	}
+	return mapFromRepository(res...), newPagination(opt.ListOptions, resp), nil
+}
+
+// GetOwnerType tells whether name is the login of a user or an organization.
+func (g *Client) GetOwnerType(ctx context.Context, name string) (OwnerType, error) {
+	if err := g.rate.wait(ctx); err != nil {
+		return "", err
+	}
+	u, resp, err := g.client.Users.Get(ctx, name)
+	if err := g.rate.update(resp, err); err != nil {
+		return "", err
+	}
+	if OwnerType(u.GetType()) == OwnerOrganization {
+		return OwnerOrganization, nil
+	}
+	// Bots are listed like users.
+	return OwnerUser, nil
+}
+
+// ListAllRepositories follows the pagination of the github api starting at opt.Page and streams
+// every page on the returned channel. The channel is closed after the last page, after the
+// first error, once the configured maximum number of pages is reached or when ctx is done.
+func (g *Client) ListAllRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) <-chan RepositoryPage {
+	pages := make(chan RepositoryPage)
+	o := github.RepositoryListOptions{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.Page == 0 {
+		o.Page = 1
+	}
+	go func() {
+		defer close(pages)
+		for n := 0; n < g.pageLimit(); n++ {
+			repos, resp, err := g.listRepositories(ctx, username, &o)
+			select {
+			case pages <- RepositoryPage{Repositories: repos, Page: o.Page, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil || resp.NextPage == 0 {
+				return
+			}
+			o.Page = resp.NextPage
+		}
+		g.log.Printf("stopped listing repositories of %s after %d pages", username, g.pageLimit())
+	}()
+	return pages
+}
+
+func (g *Client) pageLimit() int {
+	if g.maxPages > 0 {
+		return g.maxPages
+	}
+	return defaultMaxPages
 }
 
 // ListCommits lists the commits of a repository
-func (g *Client) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, error) {
-	res, _, err := g.client.Repositories.ListCommits(ctx, username, repoName, opt)
-	if err != nil {
-		return nil, err
+func (g *Client) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*Commit, *Pagination, error) {
+	if opt == nil {
+		opt = &github.CommitsListOptions{}
+	}
+	if err := g.rate.wait(ctx); err != nil {
+		return nil, nil, err
 	}
-	return mapFromCommit(res...), nil
+	res, resp, err := g.client.Repositories.ListCommits(ctx, username, repoName, opt)
+	if err := g.rate.update(resp, err); err != nil {
+		return nil, nil, err
+	}
+	return mapFromCommit(res...), newPagination(opt.ListOptions, resp), nil
+}
+
+// Quota returns the last known github api rate limit.
+func (g *Client) Quota() Quota {
+	return g.rate.current()
 }
 
 type Repository struct {
-	ID         int64 `gorm:"primaryKey"`
-	NodeID     string
-	Owner      string `gorm:"index"`
-	Name       string
-	CreatedAt  time.Time
-	LastAccess time.Time
+	ID            int64 `gorm:"primaryKey"`
+	NodeID        string
+	Owner         string `gorm:"index"`
+	OwnerType     OwnerType
+	Name          string
+	Description   string
+	HTMLURL       string
+	DefaultBranch string
+	Language      string         `gorm:"index"`
+	Topics        pq.StringArray `gorm:"type:text[]"`
+	Stars         int
+	Forks         int
+	OpenIssues    int
+	Archived      bool
+	Fork          bool
+	Private       bool
+	CreatedAt     time.Time
+	PushedAt      time.Time
+	// GithubUpdatedAt is when the repository was last updated on github. It is not called UpdatedAt,
+	// which gorm would overwrite whenever the row is saved.
+	GithubUpdatedAt time.Time
+	LastAccess      time.Time
 }
 
 func mapFromRepository(in ...*github.Repository) []*Repository {
 	var res []*Repository
 	for _, v := range in {
-		repo := Repository{}
+		repo := Repository{
+			ID:            v.GetID(),
+			NodeID:        v.GetNodeID(),
+			Name:          v.GetName(),
+			Description:   v.GetDescription(),
+			HTMLURL:       v.GetHTMLURL(),
+			DefaultBranch: v.GetDefaultBranch(),
+			Language:      v.GetLanguage(),
+			Topics:        v.Topics,
+			Stars:         v.GetStargazersCount(),
+			Forks:         v.GetForksCount(),
+			OpenIssues:    v.GetOpenIssuesCount(),
+			Archived:      v.GetArchived(),
+			Fork:          v.GetFork(),
+			Private:       v.GetPrivate(),
+		}
 		if v.Owner != nil {
-			repo.Owner = *v.Owner.Login
+			repo.Owner = v.Owner.GetLogin()
+			repo.OwnerType = OwnerType(v.Owner.GetType())
 		}
 		if v.CreatedAt != nil {
 			repo.CreatedAt = v.CreatedAt.Time
 		}
-		if v.ID != nil {
-			repo.ID = *v.ID
-		}
-		if v.NodeID != nil {
-			repo.NodeID = *v.NodeID
+		if v.PushedAt != nil {
+			repo.PushedAt = v.PushedAt.Time
 		}
-
-		if v.Name != nil {
-			repo.Name = *v.Name
+		if v.UpdatedAt != nil {
+			repo.GithubUpdatedAt = v.UpdatedAt.Time
 		}
 		res = append(res, &repo)
 	}
 	return res
 }
+
 func mapToRepository(in ...Repository) []*github.Repository {
 	var res []*github.Repository
 	for _, v := range in {
+		v := v
 		res = append(res, &github.Repository{
-			ID:        &v.ID,
-			Owner:     &github.User{Login: &v.Owner},
-			CreatedAt: &github.Timestamp{Time: v.CreatedAt},
-			NodeID:    &v.NodeID,
-			Name:      &v.Name,
+			ID:              &v.ID,
+			Owner:           &github.User{Login: &v.Owner, Type: (*string)(&v.OwnerType)},
+			CreatedAt:       &github.Timestamp{Time: v.CreatedAt},
+			PushedAt:        &github.Timestamp{Time: v.PushedAt},
+			UpdatedAt:       &github.Timestamp{Time: v.GithubUpdatedAt},
+			NodeID:          &v.NodeID,
+			Name:            &v.Name,
+			Description:     &v.Description,
+			HTMLURL:         &v.HTMLURL,
+			DefaultBranch:   &v.DefaultBranch,
+			Language:        &v.Language,
+			Topics:          v.Topics,
+			StargazersCount: &v.Stars,
+			ForksCount:      &v.Forks,
+			OpenIssuesCount: &v.OpenIssues,
+			Archived:        &v.Archived,
+			Fork:            &v.Fork,
+			Private:         &v.Private,
 		})
 	}
 	return res
 }
 
 type Commit struct {
-	NodeID      string
-	SHA         string `gorm:"primaryKey"`
-	Author      string
+	// Owner and Repository name the repository the commit was listed for. Along with the SHA they
+	// identify a commit, since forks share the SHAs of their parent.
+	Owner        string `gorm:"primaryKey"`
+	Repository   string `gorm:"primaryKey"`
+	SHA          string `gorm:"primaryKey"`
+	NodeID       string
+	RepositoryID int64 `gorm:"index"`
+	Message      string
+	// Author and Committer are the logins of the github users, which are empty when github
+	// could not link the git identity to a user.
+	Author         string
+	AuthorName     string
+	AuthorEmail    string
+	AuthoredAt     time.Time
+	Committer      string
+	CommitterName  string
+	CommitterEmail string
+	CommittedAt    time.Time
+	Parents        pq.StringArray `gorm:"type:text[]"`
+	Verified       bool
+	// VerificationReason tells why the signature of the commit is (not) verified, e.g. unsigned.
+	VerificationReason string
+	// Additions, Deletions and Changes count the changed lines. Github only reports them for a
+	// single commit, they are zero for listed commits.
+	Additions   int
+	Deletions   int
+	Changes     int
 	CommentsURL string
+	HTMLURL     string
 }
 
 func mapFromCommit(in ...*github.RepositoryCommit) []*Commit {
 	var res []*Commit
 	for _, v := range in {
-		commit := Commit{}
-		if v.CommentsURL != nil {
-			commit.CommentsURL = *v.CommentsURL
-		}
-		if v.SHA != nil {
-			commit.SHA = *v.SHA
+		commit := Commit{
+			SHA:         v.GetSHA(),
+			NodeID:      v.GetNodeID(),
+			CommentsURL: v.GetCommentsURL(),
+			HTMLURL:     v.GetHTMLURL(),
+			Author:      v.GetAuthor().GetLogin(),
+			Committer:   v.GetCommitter().GetLogin(),
+			Additions:   v.GetStats().GetAdditions(),
+			Deletions:   v.GetStats().GetDeletions(),
+			Changes:     v.GetStats().GetTotal(),
 		}
-		if v.NodeID != nil {
-			commit.NodeID = *v.NodeID
+		for _, p := range v.Parents {
+			commit.Parents = append(commit.Parents, p.GetSHA())
 		}
-		if v.Author != nil && v.Author.Login != nil {
-			commit.Author = *v.Author.Login
+		if c := v.Commit; c != nil {
+			commit.Message = c.GetMessage()
+			if a := c.Author; a != nil {
+				commit.AuthorName, commit.AuthorEmail, commit.AuthoredAt = a.GetName(), a.GetEmail(), a.GetDate()
+			}
+			if cm := c.Committer; cm != nil {
+				commit.CommitterName, commit.CommitterEmail, commit.CommittedAt = cm.GetName(), cm.GetEmail(), cm.GetDate()
+			}
+			if vf := c.Verification; vf != nil {
+				commit.Verified, commit.VerificationReason = vf.GetVerified(), vf.GetReason()
+			}
 		}
 		res = append(res, &commit)
 	}
@@ -125,12 +372,25 @@ func mapFromCommit(in ...*github.RepositoryCommit) []*Commit {
 func mapToCommit(in ...*Commit) []*github.RepositoryCommit {
 	var res []*github.RepositoryCommit
 	for _, v := range in {
+		v := *v
 		commit := github.RepositoryCommit{
 			NodeID:      &v.NodeID,
 			SHA:         &v.SHA,
 			CommentsURL: &v.CommentsURL,
+			HTMLURL:     &v.HTMLURL,
+			Author:      &github.User{Login: &v.Author},
+			Committer:   &github.User{Login: &v.Committer},
+			Stats:       &github.CommitStats{Additions: &v.Additions, Deletions: &v.Deletions, Total: &v.Changes},
+			Commit: &github.Commit{
+				Message:      &v.Message,
+				Author:       &github.CommitAuthor{Name: &v.AuthorName, Email: &v.AuthorEmail, Date: &v.AuthoredAt},
+				Committer:    &github.CommitAuthor{Name: &v.CommitterName, Email: &v.CommitterEmail, Date: &v.CommittedAt},
+				Verification: &github.SignatureVerification{Verified: &v.Verified, Reason: &v.VerificationReason},
+			},
+		}
+		for i := range v.Parents {
+			commit.Parents = append(commit.Parents, &github.Commit{SHA: &v.Parents[i]})
 		}
-		commit.Author = &github.User{Login: &v.Author}
 		res = append(res, &commit)
 	}
 	return res
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -50,11 +52,23 @@ func NewFakeHttpClient(fn RoundTripFunc) *http.Client {
 
 func TestClient_ListRepositories(t *testing.T) {
 	repo1 := Repository{
-		ID:        1,
-		CreatedAt: time.Now(),
-		Name:      "blog",
-		NodeID:    "1",
-		Owner:     "me",
+		ID:              1,
+		CreatedAt:       time.Now(),
+		Name:            "blog",
+		NodeID:          "1",
+		Owner:           "me",
+		OwnerType:       OwnerUser,
+		Description:     "my blog",
+		HTMLURL:         "https://github.com/me/blog",
+		DefaultBranch:   "main",
+		Language:        "Go",
+		Topics:          []string{"blog", "hugo"},
+		Stars:           3,
+		Forks:           1,
+		OpenIssues:      2,
+		Fork:            true,
+		PushedAt:        time.Now(),
+		GithubUpdatedAt: time.Now(),
 	}
 	type fields struct {
 		client *github.Client
@@ -83,6 +97,17 @@ func TestClient_ListRepositories(t *testing.T) {
 			},
 			want: []*Repository{&repo1},
 		},
+		"nil-options": {
+			fields: fields{
+				client: NewTestClient(mapToRepository(repo1), nil),
+				log:    &log.Logger{},
+			},
+			args: args{
+				ctx:      context.Background(),
+				username: "me",
+			},
+			want: []*Repository{&repo1},
+		},
 		"error": {
 			fields: fields{
 				client: NewTestClient(nil, errors.New("not found")),
@@ -102,7 +127,7 @@ func TestClient_ListRepositories(t *testing.T) {
 				client: tt.fields.client,
 				log:    tt.fields.log,
 			}
-			got, err := g.ListRepositories(tt.args.ctx, tt.args.username, tt.args.opt)
+			got, _, err := g.ListRepositories(tt.args.ctx, tt.args.username, tt.args.opt)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.ListRepositories() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -114,12 +139,66 @@ func TestClient_ListRepositories(t *testing.T) {
 	}
 }
 
+func TestClient_ListOrgRepositories(t *testing.T) {
+	repo := Repository{ID: 1, Name: "gh-fetch", Owner: "org", OwnerType: OwnerOrganization, CreatedAt: time.Now()}
+	var path string
+	g := &Client{
+		client: github.NewClient(NewFakeHttpClient(func(req *http.Request) *http.Response {
+			path = req.URL.Path + "?" + req.URL.RawQuery
+			return jsonResponse(http.StatusOK, mapToRepository(repo))
+		})),
+		log: &log.Logger{},
+	}
+	got, _, err := g.ListOrgRepositories(context.Background(), "org", &github.RepositoryListByOrgOptions{Type: "sources"})
+	if err != nil {
+		t.Fatalf("Client.ListOrgRepositories() error = %v", err)
+	}
+	if !cmp.Equal(got, []*Repository{&repo}) {
+		t.Errorf("Client.ListOrgRepositories() = %v, want %v", got, []*Repository{&repo})
+	}
+	if want := "/orgs/org/repos?type=sources"; path != want {
+		t.Errorf("Client.ListOrgRepositories() requested %v, want %v", path, want)
+	}
+	if _, _, err := g.ListOrgRepositories(context.Background(), "org", nil); err != nil {
+		t.Errorf("Client.ListOrgRepositories() without options error = %v", err)
+	}
+}
+
+func TestClient_GetOwnerType(t *testing.T) {
+	tests := map[string]OwnerType{
+		"User":         OwnerUser,
+		"Organization": OwnerOrganization,
+		"Bot":          OwnerUser,
+	}
+	for ghType, want := range tests {
+		t.Run(ghType, func(t *testing.T) {
+			g := &Client{client: NewTestClient(&github.User{Type: github.String(ghType)}, nil), log: &log.Logger{}}
+			got, err := g.GetOwnerType(context.Background(), "name")
+			if err != nil || got != want {
+				t.Errorf("Client.GetOwnerType() = %v, %v, want %v", got, err, want)
+			}
+		})
+	}
+}
+
 func TestClient_ListCommits(t *testing.T) {
 	commit := Commit{
-		Author:      "me",
-		CommentsURL: "url",
-		NodeID:      "nodeid",
-		SHA:         "sha",
+		Author:             "me",
+		AuthorName:         "Me",
+		AuthorEmail:        "me@example.com",
+		AuthoredAt:         time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+		Committer:          "web-flow",
+		CommitterName:      "GitHub",
+		CommitterEmail:     "noreply@github.com",
+		CommittedAt:        time.Date(2020, 10, 2, 12, 0, 0, 0, time.UTC),
+		Message:            "Fix typo",
+		Parents:            []string{"parent"},
+		Verified:           true,
+		VerificationReason: "valid",
+		CommentsURL:        "url",
+		HTMLURL:            "https://github.com/me/repo/commit/sha",
+		NodeID:             "nodeid",
+		SHA:                "sha",
 	}
 	type fields struct {
 		client *github.Client
@@ -150,6 +229,18 @@ func TestClient_ListCommits(t *testing.T) {
 			},
 			want: []*Commit{&commit},
 		},
+		"nil-options": {
+			fields: fields{
+				client: NewTestClient(mapToCommit(&commit), nil),
+				log:    &log.Logger{},
+			},
+			args: args{
+				ctx:      context.Background(),
+				username: "me",
+				reponame: "repo",
+			},
+			want: []*Commit{&commit},
+		},
 		"error": {
 			fields: fields{
 				client: NewTestClient(nil, errors.New("not found")),
@@ -170,7 +261,7 @@ func TestClient_ListCommits(t *testing.T) {
 				client: tt.fields.client,
 				log:    tt.fields.log,
 			}
-			got, err := g.ListCommits(tt.args.ctx, tt.args.username, tt.args.reponame, tt.args.opt)
+			got, _, err := g.ListCommits(tt.args.ctx, tt.args.username, tt.args.reponame, tt.args.opt)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Client.Commits() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -181,3 +272,80 @@ func TestClient_ListCommits(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_ListAllRepositories(t *testing.T) {
+	newPagedClient := func(lastPage int, calls *int) *http.Client {
+		return NewFakeHttpClient(func(req *http.Request) *http.Response {
+			*calls++
+			page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+			resp := jsonResponse(http.StatusOK, mapToRepository(Repository{ID: int64(page), Owner: "me"}))
+			if page < lastPage {
+				next := *req.URL
+				q := next.Query()
+				q.Set("page", strconv.Itoa(page+1))
+				next.RawQuery = q.Encode()
+				resp.Header.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+			}
+			return resp
+		})
+	}
+	tests := map[string]struct {
+		lastPage  int
+		maxPages  int
+		wantPages []int
+	}{
+		"all-pages": {
+			lastPage:  3,
+			wantPages: []int{1, 2, 3},
+		},
+		"max-pages": {
+			lastPage:  3,
+			maxPages:  2,
+			wantPages: []int{1, 2},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			g := New(newPagedClient(tt.lastPage, &calls), log.New(ioutil.Discard, "", 0), Config{MaxPages: tt.maxPages})
+			var got []int
+			for page := range g.ListAllRepositories(context.Background(), "me", &github.RepositoryListOptions{}) {
+				if page.Err != nil {
+					t.Fatalf("Client.ListAllRepositories() error = %v", page.Err)
+				}
+				got = append(got, page.Page)
+				if len(page.Repositories) != 1 || page.Repositories[0].ID != int64(page.Page) {
+					t.Errorf("page %d = %v", page.Page, page.Repositories)
+				}
+			}
+			if !cmp.Equal(got, tt.wantPages) || calls != len(tt.wantPages) {
+				t.Errorf("pages = %v with %d requests, want %v", got, calls, tt.wantPages)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		g := &Client{client: NewTestClient(nil, errors.New("not found")), log: &log.Logger{}}
+		var pages []RepositoryPage
+		for page := range g.ListAllRepositories(context.Background(), "me", nil) {
+			pages = append(pages, page)
+		}
+		if len(pages) != 1 || pages[0].Err == nil {
+			t.Errorf("pages = %v, want a single failed page", pages)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		calls := 0
+		g := New(newPagedClient(100, &calls), &log.Logger{}, Config{MaxPages: 100})
+		ctx, cancel := context.WithCancel(context.Background())
+		pages := g.ListAllRepositories(ctx, "me", nil)
+		<-pages
+		cancel()
+		for range pages {
+		}
+		if calls > 2 {
+			t.Errorf("requests = %d, want fetching to stop after cancel", calls)
+		}
+	})
+}
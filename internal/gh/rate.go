@@ -0,0 +1,157 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/pkg/errors"
+)
+
+// defaultSecondaryRetryAfter is used when github reports a secondary rate limit without a Retry-After header.
+const defaultSecondaryRetryAfter = time.Minute
+
+// RateLimitError is returned when the github api quota is exhausted.
+type RateLimitError struct {
+	// RetryAfter is how long to wait before the next request can succeed.
+	RetryAfter time.Duration
+	// Secondary is set when github's secondary (abuse) rate limit was hit.
+	Secondary bool
+	Err       error
+}
+
+func (e *RateLimitError) Error() string {
+	kind := "rate limit"
+	if e.Secondary {
+		kind = "secondary rate limit"
+	}
+	return fmt.Sprintf("github %s exceeded, retry after %v", kind, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Quota is the last known state of the github api rate limit.
+type Quota struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	// BlockedUntil is set while a secondary rate limit is in effect.
+	BlockedUntil time.Time
+}
+
+// rateLimiter tracks the github quota and holds back requests which are bound to be rejected.
+type rateLimiter struct {
+	mu      sync.Mutex
+	quota   Quota
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+func newRateLimiter(maxWait time.Duration) *rateLimiter {
+	return &rateLimiter{maxWait: maxWait, now: time.Now}
+}
+
+// blockedFor returns how long requests have to wait until quota is available again.
+func (l *rateLimiter) blockedFor() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(l.quota.BlockedUntil) {
+		return l.quota.BlockedUntil.Sub(now), true
+	}
+	if l.quota.Limit > 0 && l.quota.Remaining <= 0 && now.Before(l.quota.Reset) {
+		return l.quota.Reset.Sub(now), false
+	}
+	return 0, false
+}
+
+// wait blocks until quota is available, or fails fast when that takes longer than maxWait.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	d, secondary := l.blockedFor()
+	if d <= 0 {
+		return nil
+	}
+	rateErr := &RateLimitError{RetryAfter: d, Secondary: secondary}
+	if d > l.maxWait {
+		return rateErr
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update records the quota reported by github and translates rate limit errors.
+func (l *rateLimiter) update(resp *github.Response, err error) error {
+	if l == nil {
+		return translateRateLimit(err, time.Now())
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if resp != nil && resp.Rate.Limit > 0 {
+		l.quota.Limit = resp.Rate.Limit
+		l.quota.Remaining = resp.Rate.Remaining
+		l.quota.Reset = resp.Rate.Reset.Time
+	}
+	err = translateRateLimit(err, l.now())
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) && rateErr.Secondary {
+		l.quota.BlockedUntil = l.now().Add(rateErr.RetryAfter)
+	}
+	return err
+}
+
+func (l *rateLimiter) current() Quota {
+	if l == nil {
+		return Quota{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.quota
+}
+
+func translateRateLimit(err error, now time.Time) error {
+	var primary *github.RateLimitError
+	if errors.As(err, &primary) {
+		return &RateLimitError{RetryAfter: positive(primary.Rate.Reset.Time.Sub(now)), Err: err}
+	}
+	var secondary *github.AbuseRateLimitError
+	if errors.As(err, &secondary) {
+		retryAfter := defaultSecondaryRetryAfter
+		if secondary.RetryAfter != nil {
+			retryAfter = *secondary.RetryAfter
+		}
+		return &RateLimitError{RetryAfter: positive(retryAfter), Secondary: true, Err: err}
+	}
+	// Newer secondary rate limit responses are not recognised by go-github, they only carry a Retry-After header.
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		resp := errResp.Response
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+				return &RateLimitError{RetryAfter: positive(time.Duration(secs) * time.Second), Secondary: true, Err: err}
+			}
+		}
+	}
+	return err
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < time.Second {
+		return time.Second
+	}
+	return d
+}
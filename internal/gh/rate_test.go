@@ -0,0 +1,112 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
+
+func TestClient_RateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	tests := map[string]struct {
+		response      func() *http.Response
+		wantSecondary bool
+	}{
+		"primary": {
+			response: func() *http.Response {
+				resp := jsonResponse(http.StatusForbidden, map[string]string{"message": "API rate limit exceeded"})
+				resp.Header.Set("X-RateLimit-Limit", "60")
+				resp.Header.Set("X-RateLimit-Remaining", "0")
+				resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				return resp
+			},
+		},
+		"secondary": {
+			response: func() *http.Response {
+				resp := jsonResponse(http.StatusForbidden, map[string]string{
+					"message":           "You have triggered an abuse detection mechanism.",
+					"documentation_url": "https://developer.github.com/v3/#abuse-rate-limits",
+				})
+				resp.Header.Set("Retry-After", "30")
+				return resp
+			},
+			wantSecondary: true,
+		},
+		"secondary-retry-after-only": {
+			response: func() *http.Response {
+				resp := jsonResponse(http.StatusTooManyRequests, map[string]string{"message": "secondary rate limit"})
+				resp.Header.Set("Retry-After", "30")
+				return resp
+			},
+			wantSecondary: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			fakeHttpClient := NewFakeHttpClient(func(req *http.Request) *http.Response {
+				calls++
+				resp := tt.response()
+				resp.Request = req
+				return resp
+			})
+			g := New(fakeHttpClient, &log.Logger{}, Config{})
+			for i := 0; i < 2; i++ {
+				_, _, err := g.ListRepositories(context.Background(), "me", &github.RepositoryListOptions{})
+				var rateErr *RateLimitError
+				if !errors.As(err, &rateErr) {
+					t.Fatalf("Client.ListRepositories() error = %v, want RateLimitError", err)
+				}
+				if rateErr.Secondary != tt.wantSecondary || rateErr.RetryAfter < time.Second {
+					t.Errorf("RateLimitError = %+v, want secondary %v with a retry after", rateErr, tt.wantSecondary)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("requests = %d, want the second request to fail fast", calls)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_wait(t *testing.T) {
+	t.Run("available", func(t *testing.T) {
+		l := newRateLimiter(0)
+		l.quota = Quota{Limit: 60, Remaining: 1, Reset: time.Now().Add(time.Hour)}
+		if err := l.wait(context.Background()); err != nil {
+			t.Errorf("wait() error = %v", err)
+		}
+	})
+
+	t.Run("block-until-reset", func(t *testing.T) {
+		l := newRateLimiter(time.Second)
+		l.quota = Quota{Limit: 60, Remaining: 0, Reset: time.Now().Add(20 * time.Millisecond)}
+		if err := l.wait(context.Background()); err != nil {
+			t.Errorf("wait() error = %v", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		l := newRateLimiter(time.Hour)
+		l.quota = Quota{Limit: 60, Remaining: 0, Reset: time.Now().Add(time.Minute)}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := l.wait(ctx); err != context.Canceled {
+			t.Errorf("wait() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("fail-fast", func(t *testing.T) {
+		l := newRateLimiter(time.Second)
+		l.quota = Quota{Limit: 60, Remaining: 0, Reset: time.Now().Add(time.Minute)}
+		var rateErr *RateLimitError
+		if err := l.wait(context.Background()); !errors.As(err, &rateErr) {
+			t.Errorf("wait() error = %v, want RateLimitError", err)
+		}
+	})
+}
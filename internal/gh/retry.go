@@ -0,0 +1,142 @@
+package gh
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 250 * time.Millisecond
+	defaultRetryMaxDelay  = 5 * time.Second
+)
+
+// RetryPolicy configures how requests failing transiently are retried.
+type RetryPolicy struct {
+	// MaxAttempts caps the number of attempts of a request, including the first. Defaults to 3,
+	// a single attempt disables retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, which doubles with every further retry. Defaults to 250ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. A Retry-After asking for longer is not retried. Defaults to 5s.
+	MaxDelay time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	return p
+}
+
+// backoff returns the delay before the retry following attempt, which starts at 1.
+// The delay grows exponentially and is jittered by up to half of it.
+func (p RetryPolicy) backoff(attempt int, jitter func() float64) time.Duration {
+	d := p.BaseDelay << uint(attempt-1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	return d/2 + time.Duration(jitter()*float64(d/2))
+}
+
+// retryTransport retries idempotent requests which failed on 5xx responses, timeouts or connection errors.
+type retryTransport struct {
+	policy RetryPolicy
+	base   http.RoundTripper
+	jitter func() float64
+	now    func() time.Time
+}
+
+func newRetryTransport(policy RetryPolicy, base http.RoundTripper) *retryTransport {
+	return &retryTransport{policy: policy.withDefaults(), base: base, jitter: rand.Float64, now: time.Now}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+		delay, ok := t.delay(ctx, attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// delay returns how long to wait before retrying, or false when the wait would exceed a
+// Retry-After the policy allows or the deadline of the request.
+func (t *retryTransport) delay(ctx context.Context, attempt int, resp *http.Response) (time.Duration, bool) {
+	delay := t.policy.backoff(attempt, t.jitter)
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter := time.Duration(secs) * time.Second
+			if retryAfter > t.policy.MaxDelay {
+				return 0, false
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && t.now().Add(delay).After(deadline) {
+		return 0, false
+	}
+	return delay, true
+}
+
+// retryable reports whether an attempt failed transiently.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return transientError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// transientError reports whether a request failed by timing out or losing its connection. Other
+// errors, e.g. of certificates, DNS lookups or unsupported URLs, are bound to fail again.
+func transientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,174 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
+)
+
+func statusResponse(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: ioutil.NopCloser(bytes.NewReader(nil))}
+}
+
+type failingTransport struct {
+	fails int
+	err   error
+	calls int
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	if f.calls <= f.fails {
+		return nil, f.err
+	}
+	return statusResponse(http.StatusOK, nil), nil
+}
+
+func TestRetryTransport_RoundTrip(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	tests := map[string]struct {
+		method       string
+		statuses     []int
+		header       http.Header
+		timeout      time.Duration
+		wantStatus   int
+		wantAttempts int
+	}{
+		"ok":                  {statuses: []int{200}, wantStatus: 200, wantAttempts: 1},
+		"bad-gateway":         {statuses: []int{502, 200}, wantStatus: 200, wantAttempts: 2},
+		"gives-up":            {statuses: []int{503, 503, 503, 200}, wantStatus: 503, wantAttempts: 3},
+		"not-found":           {statuses: []int{404, 200}, wantStatus: 404, wantAttempts: 1},
+		"not-idempotent":      {method: http.MethodPost, statuses: []int{502, 200}, wantStatus: 502, wantAttempts: 1},
+		"retry-after-too-far": {statuses: []int{503, 200}, header: http.Header{"Retry-After": {"60"}}, wantStatus: 503, wantAttempts: 1},
+		"past-deadline": {
+			statuses: []int{502, 200}, timeout: time.Microsecond, wantStatus: 502, wantAttempts: 1,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			transport := newRetryTransport(policy, RoundTripFunc(func(req *http.Request) *http.Response {
+				status := tt.statuses[attempts]
+				attempts++
+				return statusResponse(status, tt.header)
+			}))
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, _ := http.NewRequestWithContext(ctx, method, "https://api.github.com/users/me/repos", nil)
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || attempts != tt.wantAttempts {
+				t.Errorf("RoundTrip() = %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.wantStatus, tt.wantAttempts)
+			}
+		})
+	}
+
+	t.Run("connection-error", func(t *testing.T) {
+		base := &failingTransport{fails: 2, err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+		req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/users/me/repos", nil)
+		resp, err := newRetryTransport(policy, base).RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusOK || base.calls != 3 {
+			t.Errorf("RoundTrip() = %v, %v after %d calls, want 200 after 3", resp, err, base.calls)
+		}
+	})
+
+	t.Run("permanent-error", func(t *testing.T) {
+		for _, baseErr := range []error{
+			x509.UnknownAuthorityError{},
+			&net.DNSError{Err: "no such host", Name: "api.github.com", IsNotFound: true},
+			errors.New("unsupported protocol scheme"),
+		} {
+			base := &failingTransport{fails: 1, err: baseErr}
+			req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/users/me/repos", nil)
+			if _, err := newRetryTransport(policy, base).RoundTrip(req); err == nil || base.calls != 1 {
+				t.Errorf("RoundTrip() error = %v after %d calls, want %v after 1", err, base.calls, baseErr)
+			}
+		}
+	})
+
+	t.Run("cancelled-while-waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		transport := newRetryTransport(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}, RoundTripFunc(func(req *http.Request) *http.Response {
+			time.AfterFunc(10*time.Millisecond, cancel)
+			return statusResponse(http.StatusBadGateway, nil)
+		}))
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/users/me/repos", nil)
+		if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+			t.Errorf("RoundTrip() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestRetryTransport_delay(t *testing.T) {
+	transport := newRetryTransport(RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}, nil)
+	transport.jitter = func() float64 { return 1 }
+	tests := map[string]struct {
+		attempt int
+		header  http.Header
+		want    time.Duration
+		wantOK  bool
+	}{
+		"first":             {attempt: 1, want: time.Second, wantOK: true},
+		"exponential":       {attempt: 3, want: 4 * time.Second, wantOK: true},
+		"capped":            {attempt: 10, want: 10 * time.Second, wantOK: true},
+		"retry-after":       {attempt: 1, header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second, wantOK: true},
+		"retry-after-short": {attempt: 3, header: http.Header{"Retry-After": {"1"}}, want: 4 * time.Second, wantOK: true},
+		"retry-after-long":  {attempt: 1, header: http.Header{"Retry-After": {"11"}}, wantOK: false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := transport.delay(context.Background(), tt.attempt, statusResponse(http.StatusServiceUnavailable, tt.header))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("delay() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	t.Run("jitter", func(t *testing.T) {
+		transport.jitter = func() float64 { return 0 }
+		if got, _ := transport.delay(context.Background(), 1, statusResponse(http.StatusBadGateway, nil)); got != 500*time.Millisecond {
+			t.Errorf("delay() = %v, want half the backoff without jitter", got)
+		}
+	})
+}
+
+func TestClient_retries(t *testing.T) {
+	repo := Repository{ID: 1, CreatedAt: time.Now().UTC().Truncate(time.Second), Name: "blog", Owner: "me"}
+	attempts := 0
+	fake := NewFakeHttpClient(func(req *http.Request) *http.Response {
+		attempts++
+		if attempts == 1 {
+			return statusResponse(http.StatusBadGateway, nil)
+		}
+		return jsonResponse(http.StatusOK, mapToRepository(repo))
+	})
+	g := New(fake, log.New(ioutil.Discard, "", 0), Config{Retry: RetryPolicy{BaseDelay: time.Millisecond}})
+	got, _, err := g.ListRepositories(context.Background(), "me", &github.RepositoryListOptions{})
+	if err != nil || !cmp.Equal(got, []*Repository{&repo}) || attempts != 2 {
+		t.Errorf("Client.ListRepositories() = %v, %v after %d attempts, want the repositories after 2", got, err, attempts)
+	}
+}
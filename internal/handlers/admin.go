@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/karthikraobr/gh-fetch/internal/cache"
+)
+
+// cacheStats are the counters of the cache along with the ratio of reads served from it.
+type cacheStats struct {
+	cache.Stats
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// HandleCacheStats returns the counters of the cache.
+func (h *Handler) HandleCacheStats() func(c *gin.Context) {
+	return h.cacheStatsHandler
+}
+
+func (h *Handler) cacheStatsHandler(c *gin.Context) {
+	stats := h.cache.Stats()
+	c.JSON(http.StatusOK, cacheStats{Stats: stats, HitRatio: stats.HitRatio()})
+}
+
+// HandleCacheKeys lists the cached keys of pages, remembered failures and owner types, optionally only those starting with the prefix query parameter.
+func (h *Handler) HandleCacheKeys() func(c *gin.Context) {
+	return h.cacheKeysHandler
+}
+
+func (h *Handler) cacheKeysHandler(c *gin.Context) {
+	prefix := c.Query("prefix")
+	c.JSON(http.StatusOK, gin.H{
+		"repositories": withPrefix(h.repositories.Keys(), prefix),
+		"commits":      withPrefix(h.commits.Keys(), prefix),
+		"failures":     withPrefix(h.failures.Keys(), prefix),
+		"owners":       withPrefix(h.owners.Keys(), prefix),
+	})
+}
+
+// HandleCachePurge removes the entry of the key query parameter or every entry starting with the
+// prefix query parameter, e.g. `username/` to invalidate everything cached for a user.
+func (h *Handler) HandleCachePurge() func(c *gin.Context) {
+	return h.cachePurgeHandler
+}
+
+func (h *Handler) cachePurgeHandler(c *gin.Context) {
+	purged := 0
+	if key := c.Query("key"); key != "" {
+		for _, deleted := range []bool{h.repositories.Delete(key), h.commits.Delete(key), h.failures.Delete(key), h.owners.Delete(key)} {
+			if deleted {
+				purged++
+			}
+		}
+	} else if prefix := c.Query("prefix"); prefix != "" {
+		purged = h.repositories.DeletePrefix(prefix) + h.commits.DeletePrefix(prefix) + h.failures.DeletePrefix(prefix) + h.owners.DeletePrefix(prefix)
+	} else {
+		c.Error(NewHttpError(http.StatusBadRequest, errors.New("key or prefix required")))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+func withPrefix(keys []string, prefix string) []string {
+	matched := []string{}
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			matched = append(matched, k)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v32/github"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+	"github.com/karthikraobr/gh-fetch/internal/store"
+)
+
+// maxFilterLength caps the length of free-form filter values.
+const maxFilterLength = 255
+
+// commitOptions reads the sha, path, author, since and until query parameters filtering commits.
+// Since and until are RFC 3339 timestamps.
+func commitOptions(c *gin.Context, page, perPage int) (github.CommitsListOptions, error) {
+	opt := github.CommitsListOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+	for _, p := range []struct {
+		name string
+		dst  *string
+	}{{"sha", &opt.SHA}, {"path", &opt.Path}, {"author", &opt.Author}} {
+		v := c.Query(p.name)
+		if len(v) > maxFilterLength || strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			return opt, invalidParam(p.name, "must be at most %d printable characters", maxFilterLength)
+		}
+		*p.dst = v
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &opt.Since}, {"until", &opt.Until}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opt, invalidParam(p.name, "must be an RFC 3339 timestamp, e.g. 2020-10-01T00:00:00Z")
+		}
+		*p.dst = t.UTC()
+	}
+	if !opt.Since.IsZero() && !opt.Until.IsZero() && opt.Since.After(opt.Until) {
+		return opt, invalidParam("since", "must not be after until")
+	}
+	return opt, nil
+}
+
+// repositorySorts maps the sort query parameter to the column the store sorts by.
+var repositorySorts = map[string]store.SortField{
+	"full_name": store.SortByName,
+	"created":   store.SortByCreatedAt,
+	"updated":   store.SortByUpdatedAt,
+	"pushed":    store.SortByPushedAt,
+}
+
+// repositoryFilter selects repositories by what github does not filter by itself. It is applied
+// to pages as they are served, so that cached pages are shared regardless of the filters. Pages
+// from the store are filtered alike, a page holds the same repositories wherever it comes from.
+type repositoryFilter struct {
+	language string
+	archived *bool
+	fork     *bool
+	// private is always false. The github client may be authenticated with a token reaching private
+	// repositories, which must not be listed to anonymous clients.
+	private *bool
+}
+
+// repositoryOptions reads the type, sort and direction query parameters of the repositories of a user
+// along with the filters. Github does not list forks or sources of users, which are filtered by the fork flag.
+func repositoryOptions(c *gin.Context, page, perPage int) (github.RepositoryListOptions, repositoryFilter, error) {
+	opt := github.RepositoryListOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+	f, err := repositoryFilters(c)
+	if err != nil {
+		return opt, f, err
+	}
+	switch t := c.DefaultQuery("type", "owner"); t {
+	case "all", "owner", "member":
+		opt.Type = t
+	case "forks", "sources":
+		opt.Type = "owner"
+		f.fork = boolPtr(t == "forks")
+	default:
+		return opt, f, invalidParam("type", "must be one of all, owner, member, forks or sources")
+	}
+	opt.Sort, opt.Direction, err = sortParams(c)
+	return opt, f, err
+}
+
+// orgRepositoryOptions reads the type, sort and direction query parameters of the repositories of an
+// organization along with the filters.
+func orgRepositoryOptions(c *gin.Context, page, perPage int) (github.RepositoryListByOrgOptions, repositoryFilter, error) {
+	opt := github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+	f, err := repositoryFilters(c)
+	if err != nil {
+		return opt, f, err
+	}
+	switch t := c.DefaultQuery("type", "all"); t {
+	case "forks", "sources":
+		// Github filters by itself, the flag lets the store do the same.
+		f.fork = boolPtr(t == "forks")
+		fallthrough
+	case "all", "public", "member":
+		opt.Type = t
+	default:
+		// Github reports internal repositories as private, a page of them would always be empty.
+		return opt, f, invalidParam("type", "must be one of all, public, forks, sources or member, private and internal repositories are never listed")
+	}
+	opt.Sort, opt.Direction, err = sortParams(c)
+	return opt, f, err
+}
+
+// sortParams reads the sort and direction query parameters of repositories.
+func sortParams(c *gin.Context) (sort, direction string, err error) {
+	sort = c.DefaultQuery("sort", "full_name")
+	if _, ok := repositorySorts[sort]; !ok {
+		return "", "", invalidParam("sort", "must be one of created, updated, pushed or full_name")
+	}
+	// Like github, names are sorted ascending and dates descending by default.
+	direction = "desc"
+	if sort == "full_name" {
+		direction = "asc"
+	}
+	switch d := c.DefaultQuery("direction", direction); d {
+	case "asc", "desc":
+		return sort, d, nil
+	default:
+		return "", "", invalidParam("direction", "must be asc or desc")
+	}
+}
+
+// repositoryFilters reads the language and archived query parameters.
+func repositoryFilters(c *gin.Context) (repositoryFilter, error) {
+	f := repositoryFilter{private: boolPtr(false)}
+	f.language = c.Query("language")
+	if len(f.language) > maxFilterLength || strings.IndexFunc(f.language, unicode.IsControl) >= 0 {
+		return f, invalidParam("language", "must be at most %d printable characters", maxFilterLength)
+	}
+	if v := c.Query("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, invalidParam("archived", "must be true or false")
+		}
+		f.archived = &archived
+	}
+	return f, nil
+}
+
+// match reports whether r passes the filter.
+func (f repositoryFilter) match(r *gh.Repository) bool {
+	return (f.language == "" || strings.EqualFold(f.language, r.Language)) &&
+		(f.archived == nil || *f.archived == r.Archived) &&
+		(f.fork == nil || *f.fork == r.Fork) &&
+		(f.private == nil || *f.private == r.Private)
+}
+
+// apply returns the repositories passing the filter.
+func (f repositoryFilter) apply(repos []*gh.Repository) []*gh.Repository {
+	res := []*gh.Repository{}
+	for _, r := range repos {
+		if f.match(r) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// repositoryQuery returns the store query selecting the page github would have listed. The filters
+// are left to the handler, which applies them to the page like it does to pages listed by github.
+func repositoryQuery(owner, sort, direction string, opt github.ListOptions) *store.RepositoryQuery {
+	return &store.RepositoryQuery{
+		Owner:     owner,
+		SortBy:    repositorySorts[sort],
+		Direction: store.Direction(direction),
+		Limit:     opt.PerPage,
+		Offset:    (opt.Page - 1) * opt.PerPage,
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// invalidParam is the error of a query parameter with an invalid value.
+func invalidParam(name, format string, args ...interface{}) *HttpError {
+	return NewHttpError(http.StatusBadRequest, fmt.Errorf("invalid %s: "+format, append([]interface{}{name}, args...)...))
+}
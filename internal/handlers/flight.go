@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upstreamTimeout bounds a coalesced github call, which no longer runs on the context of a single request.
+const upstreamTimeout = 30 * time.Second
+
+// coalesce collapses concurrent calls for the same key into a single call of fn, whose result
+// every caller receives. A caller stops waiting once its own ctx is done while fn keeps running
+// for the others on a context of its own.
+func (h *Handler) coalesce(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ch := h.flight.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+		defer cancel()
+		return fn(ctx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// revalidate refreshes key in the background by calling fn, unless a call for key is already in flight.
+func (h *Handler) revalidate(key string, fn func(ctx context.Context) (interface{}, error)) {
+	// DoChan buffers the result, nobody has to receive it.
+	h.flight.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+		defer cancel()
+		val, err := fn(ctx)
+		if err != nil {
+			h.log.Println("could not refresh", key, err.Error())
+		}
+		return val, err
+	})
+}
+
+// clientGone reports whether the client went away, in which case there is nobody to respond to.
+func clientGone(c *gin.Context) bool {
+	return c.Request.Context().Err() != nil
+}
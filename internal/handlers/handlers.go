@@ -1,91 +1,192 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v32/github"
 	"github.com/karthikraobr/gh-fetch/internal/cache"
 	"github.com/karthikraobr/gh-fetch/internal/gh"
 	"github.com/karthikraobr/gh-fetch/internal/store"
+	"golang.org/x/sync/singleflight"
 )
 
 type Handler struct {
-	log    *log.Logger
-	client gh.Fetcher
-	cache  *cache.TTLCache
-	store  store.DB
+	log          *log.Logger
+	client       gh.Fetcher
+	cache        cache.Cache[string, any]
+	repositories *cache.View[*repositoryPage]
+	commits      *cache.View[*commitPage]
+	failures     *cache.View[*upstreamFailure]
+	owners       *cache.View[gh.OwnerType]
+	negativeTTL  time.Duration
+	store        store.DB
+	flight       singleflight.Group
+	adminToken   string
 }
 
 // New initializes the handler struct
-func New(client gh.Fetcher, log *log.Logger, store store.DB, cache *cache.TTLCache) *Handler {
+func New(client gh.Fetcher, log *log.Logger, store store.DB, c cache.Cache[string, any]) *Handler {
 	return &Handler{
-		log:    log,
-		client: client,
-		store:  store,
-		cache:  cache,
+		log:          log,
+		client:       client,
+		store:        store,
+		cache:        c,
+		repositories: cache.NewView[*repositoryPage](c, "repositories:"),
+		commits:      cache.NewView[*commitPage](c, "commits:"),
+		failures:     cache.NewView[*upstreamFailure](c, "failures:"),
+		owners:       cache.NewView[gh.OwnerType](c, "owners:"),
+		negativeTTL:  defaultNegativeTTL,
 	}
 }
 
 // SetUpRouter assigns the handlers to the URLs
 func (h *Handler) SetUpRouter() *gin.Engine {
 	r := gin.Default()
-	r.Use(ErrorHandler())
+	r.Use(RequestID(), ErrorHandler(h.log))
+	r.GET("/health", h.HandleHealth())
 	r.GET("/user/:username/repositories", h.HandleRepositories())
+	r.GET("/org/:org/repositories", h.HandleOrgRepositories())
+	r.GET("/owner/:owner/repositories", h.HandleOwnerRepositories())
 	r.GET("/user/:username/repository/:repository/commits", h.HandleCommits())
 	r.GET("/user/:username/top20", h.HandleTop20())
+	if h.adminToken != "" {
+		// Syncing spends up to GITHUB_MAX_PAGES requests of the quota bypassing the cache, it is left to admins.
+		r.POST("/user/:username/sync", AdminAuth(h.adminToken), h.HandleSync())
+		admin := r.Group("/admin", AdminAuth(h.adminToken))
+		admin.GET("/cache/stats", h.HandleCacheStats())
+		admin.GET("/cache/keys", h.HandleCacheKeys())
+		admin.DELETE("/cache", h.HandleCachePurge())
+	}
 	return r
 }
 
+// WithAdminToken enables the admin routes and syncing for requests carrying token as a bearer token.
+func (h *Handler) WithAdminToken(token string) *Handler {
+	h.adminToken = token
+	return h
+}
+
 // HandleRepositories fetches the public gh repositories
 func (h *Handler) HandleRepositories() func(c *gin.Context) {
 	return h.repoHandler
 }
 
 func (h *Handler) repoHandler(c *gin.Context) {
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil {
-		page = 1
-	}
-	perPage, err := strconv.Atoi(c.DefaultQuery("perpage", "20"))
-	if err != nil {
-		perPage = 20
-	}
 	username := c.Param("username")
 	if username == "" {
 		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty username")))
 		return
 	}
-	// Enable cache pagination. This would make cache management difficult. Due to lack of time this is being skipped rn.
-	// Due to this results will be inconsistent and not follow page and perpage requests.
-	cacheVal := h.cache.Get(username)
-	if cacheVal != nil {
-		if val, ok := cacheVal.([]*gh.Repository); ok {
-			c.JSON(http.StatusOK, val)
-			return
+	h.userRepositories(c, username)
+}
+
+// userRepositories serves a page of the repositories of a user.
+func (h *Handler) userRepositories(c *gin.Context, username string) {
+	page, perPage := pageParams(c)
+	opt, filter, err := repositoryOptions(c, page, perPage)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	l := repositoryListing{
+		owner:  username,
+		key:    repositoriesKey(username, &opt),
+		filter: filter,
+		list: func(ctx context.Context) ([]*gh.Repository, *gh.Pagination, error) {
+			return h.client.ListRepositories(ctx, username, &opt)
+		},
+	}
+	// The store only knows the owner of a repository, not who else is a member.
+	if opt.Type == "owner" {
+		l.query = repositoryQuery(username, opt.Sort, opt.Direction, opt.ListOptions)
+	}
+	h.serveRepositories(c, l)
+}
+
+// repositoryListing is a page of the repositories of an owner.
+type repositoryListing struct {
+	owner string
+	// key is the cache key of the page.
+	key  string
+	list func(ctx context.Context) ([]*gh.Repository, *gh.Pagination, error)
+	// filter is applied to the page listed by github.
+	filter repositoryFilter
+	// query selects the page from the store when github fails, nil when the store cannot serve it.
+	query *store.RepositoryQuery
+}
+
+// serveRepositories serves a page of repositories from the cache, github or the store.
+func (h *Handler) serveRepositories(c *gin.Context, l repositoryListing) {
+	fKey := l.owner + "/repositories"
+	if f, ok := h.failures.Get(fKey); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusFresh))
+		return
+	}
+	fetch := h.fetchRepositories(l.key, fKey, l.list)
+	if val, state := h.repositories.GetWithState(l.key); state != cache.Miss {
+		if state == cache.Stale {
+			h.revalidate(l.key, fetch)
 		}
+		c.Header(cacheStatusHeader, cacheStatus(state))
+		writePagination(c, &val.Pagination)
+		c.JSON(http.StatusOK, l.filter.apply(val.Repositories))
+		return
+	}
+	val, err := h.coalesce(c.Request.Context(), l.key, fetch)
+	if clientGone(c) {
+		return
+	}
+	if f, ok := negativeFailure(err); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusMiss))
+		return
 	}
-	opt := github.RepositoryListOptions{Type: "public", ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
-	repos, err := h.client.ListRepositories(c, username, &opt)
 	if err != nil {
-		repos, err := h.store.GetRepositories(username)
-		if err != nil {
-			c.Error(NewHttpError(http.StatusInternalServerError, err))
+		if l.query == nil {
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, err)))
 			return
 		}
-		c.JSON(http.StatusOK, repos)
-		return
-	}
-	h.cache.Put(username, repos)
-	c.JSON(http.StatusOK, repos)
-	if _, err := h.store.CreateRepositories(repos); err != nil {
-		h.log.Println("error in creating rows", err.Error())
+		stored, dbErr := h.store.QueryRepositories(*l.query)
+		if dbErr != nil {
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, dbErr)))
+			return
+		}
+		if stored.Total == 0 {
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, err)))
+			return
+		}
+		page := l.query.Offset/l.query.Limit + 1
+		c.Header(cacheStatusHeader, cacheStatusDB)
+		writePagination(c, storePagination(page, l.query.Limit, stored.Total))
+		c.JSON(http.StatusOK, l.filter.apply(stored.Repositories))
 		return
 	}
+	res := val.(*repositoryPage)
+	c.Header(cacheStatusHeader, cacheStatusMiss)
+	writePagination(c, &res.Pagination)
+	c.JSON(http.StatusOK, l.filter.apply(res.Repositories))
+}
 
+// fetchRepositories returns the call fetching a page of repositories from github into the cache and the store.
+func (h *Handler) fetchRepositories(cKey, fKey string, list func(ctx context.Context) ([]*gh.Repository, *gh.Pagination, error)) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		repos, pagination, err := list(ctx)
+		if err != nil {
+			h.rememberFailure(fKey, err)
+			return nil, err
+		}
+		// Stored before they are cached, from then on concurrent requests may read them.
+		if _, err := h.store.CreateRepositories(repos); err != nil {
+			h.log.Println("error in creating rows", err.Error())
+		}
+		val := &repositoryPage{Repositories: repos, Pagination: *pagination}
+		h.repositories.Put(cKey, val)
+		return val, nil
+	}
 }
 
 //HandleCommits fetches the commits of a gh repository.
@@ -94,14 +195,7 @@ func (h *Handler) HandleCommits() func(c *gin.Context) {
 }
 
 func (h *Handler) commitHandler(c *gin.Context) {
-	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if err != nil {
-		page = 1
-	}
-	perPage, err := strconv.Atoi(c.DefaultQuery("perpage", "20"))
-	if err != nil {
-		perPage = 20
-	}
+	page, perPage := pageParams(c)
 	username := c.Param("username")
 	if username == "" {
 		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty username")))
@@ -112,20 +206,135 @@ func (h *Handler) commitHandler(c *gin.Context) {
 		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty repo name")))
 		return
 	}
-	cKey := username + "/" + repo
-	commits := h.cache.Get(cKey)
-	if commits != nil {
-		c.JSON(http.StatusOK, commits)
+	opt, err := commitOptions(c, page, perPage)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	fKey := username + "/" + repo + "/commits"
+	if opt.SHA != "" {
+		// An unknown branch or SHA is not found either, which says nothing about the repository.
+		fKey += "@" + opt.SHA
+	}
+	if f, ok := h.failures.Get(fKey); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusFresh))
+		return
+	}
+	cKey := commitsKey(username, repo, &opt)
+	fetch := h.fetchCommits(cKey, fKey, username, repo, &opt)
+	if val, state := h.commits.GetWithState(cKey); state != cache.Miss {
+		if state == cache.Stale {
+			h.revalidate(cKey, fetch)
+		}
+		c.Header(cacheStatusHeader, cacheStatus(state))
+		writePagination(c, &val.Pagination)
+		c.JSON(http.StatusOK, val.Commits)
+		return
+	}
+	val, err := h.coalesce(c.Request.Context(), cKey, fetch)
+	if clientGone(c) {
+		return
+	}
+	if f, ok := negativeFailure(err); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusMiss))
 		return
 	}
-	opt := github.CommitsListOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
-	commits, err = h.client.ListCommits(c, username, repo, &opt)
 	if err != nil {
-		c.Error(NewHttpError(http.StatusInternalServerError, err))
+		if opt.SHA != "" || opt.Path != "" {
+			// The store knows neither the branches nor the changed files of commits, serving
+			// every commit of the repository instead would be wrong.
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, err)))
+			return
+		}
+		stored, dbErr := h.store.QueryCommits(store.CommitQuery{
+			Owner:      username,
+			Repository: repo,
+			Author:     opt.Author,
+			Since:      opt.Since,
+			Until:      opt.Until,
+			Limit:      perPage,
+			Offset:     (page - 1) * perPage,
+		})
+		if dbErr != nil {
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, dbErr)))
+			return
+		}
+		if stored.Total == 0 {
+			c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, err)))
+			return
+		}
+		c.Header(cacheStatusHeader, cacheStatusDB)
+		writePagination(c, storePagination(page, perPage, stored.Total))
+		c.JSON(http.StatusOK, stored.Commits)
 		return
 	}
-	h.cache.Put(cKey, commits)
-	c.JSON(http.StatusOK, commits)
+	res := val.(*commitPage)
+	c.Header(cacheStatusHeader, cacheStatusMiss)
+	writePagination(c, &res.Pagination)
+	c.JSON(http.StatusOK, res.Commits)
+}
+
+// fetchCommits returns the call fetching a page of commits from github into the cache and the store.
+func (h *Handler) fetchCommits(cKey, fKey, username, repo string, opt *github.CommitsListOptions) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		commits, pagination, err := h.client.ListCommits(ctx, username, repo, opt)
+		if err != nil {
+			h.rememberFailure(fKey, err)
+			return nil, err
+		}
+		// Stored before they are cached, from then on concurrent requests may read them.
+		if _, err := h.store.CreateCommits(username, repo, commits); err != nil {
+			h.log.Println("error in creating rows", err.Error())
+		}
+		val := &commitPage{Commits: commits, Pagination: *pagination}
+		h.commits.Put(cKey, val)
+		return val, nil
+	}
+}
+
+// HandleSync fetches every page of the public gh repositories of a user into the store.
+func (h *Handler) HandleSync() func(c *gin.Context) {
+	return h.syncHandler
+}
+
+func (h *Handler) syncHandler(c *gin.Context) {
+	username := c.Param("username")
+	if username == "" {
+		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty username")))
+		return
+	}
+	opt := github.RepositoryListOptions{Type: "public", ListOptions: github.ListOptions{PerPage: 100}}
+	// Cancelling stops the producer when we bail out early.
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	pages, synced := 0, 0
+	for page := range h.client.ListAllRepositories(ctx, username, &opt) {
+		if page.Err != nil {
+			c.Error(upstreamError(page.Err, NewHttpError(http.StatusInternalServerError, page.Err)))
+			return
+		}
+		if _, err := h.store.CreateRepositories(page.Repositories); err != nil {
+			c.Error(NewHttpError(http.StatusInternalServerError, err))
+			return
+		}
+		pages++
+		synced += len(page.Repositories)
+	}
+	if clientGone(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"pages":        pages,
+		"repositories": synced,
+	})
+}
+
+// upstreamError reports the github error err if it could be classified, falling back to def.
+func upstreamError(err error, def *HttpError) error {
+	if gh.Classify(err) != gh.KindUnknown {
+		return err
+	}
+	return def
 }
 
 //HandleTop20 fetches the top 20 recently accessed repositories.
@@ -140,11 +349,18 @@ func (h *Handler) top20Handler(c *gin.Context) {
 		return
 	}
 
-	repos, err := h.store.GetRepositoriesOrderedBy(username, 20, "desc", "last_access")
+	res, err := h.store.QueryRepositories(store.RepositoryQuery{
+		Owner:     username,
+		SortBy:    store.SortByLastAccess,
+		Direction: store.Desc,
+		Limit:     20,
+		// Private repositories stored from listings of the authenticated client stay hidden.
+		Private: boolPtr(false),
+	})
 	if err != nil {
 		c.Error(NewHttpError(http.StatusInternalServerError, err))
 		return
 	}
-	c.JSON(http.StatusOK, repos)
+	c.JSON(http.StatusOK, res.Repositories)
 	return
 }
@@ -1,22 +1,40 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v32/github"
 	"github.com/karthikraobr/gh-fetch/internal/cache"
 	"github.com/karthikraobr/gh-fetch/internal/gh"
 	"github.com/karthikraobr/gh-fetch/internal/mock"
+	"github.com/karthikraobr/gh-fetch/internal/store"
+	"github.com/redis/go-redis/v9"
 )
 
+func newTestCache(t *testing.T, ln int) *cache.TTLCache[string, any] {
+	c := cache.New[string, any](ln, 60)
+	t.Cleanup(c.Close)
+	return c
+}
+
 func TestHandler_repoHandler(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
@@ -28,10 +46,10 @@ func TestHandler_repoHandler(t *testing.T) {
 			NodeID:    "1",
 			Owner:     "me"}}
 		fakeGh := mock.NewMockFetcher(ctrl)
-		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo, nil)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
 		fakeStore := mock.NewMockDB(ctrl)
 		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories", nil)
@@ -51,7 +69,7 @@ func TestHandler_repoHandler(t *testing.T) {
 		defer ctrl.Finish()
 		fakeGh := mock.NewMockFetcher(ctrl)
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user//repositories", nil)
@@ -73,10 +91,15 @@ func TestHandler_repoHandler(t *testing.T) {
 			NodeID:    "1",
 			Owner:     "me"}}
 		fakeGh := mock.NewMockFetcher(ctrl)
-		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("network issue"))
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeStore.EXPECT().GetRepositories(gomock.Any()).Return(repo, nil)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeStore.EXPECT().QueryRepositories(store.RepositoryQuery{
+			Owner:     "karthikraobr",
+			SortBy:    store.SortByName,
+			Direction: store.Asc,
+			Limit:     20,
+		}).Return(&store.RepositoryResult{Repositories: repo, Total: 1}, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories", nil)
@@ -89,26 +112,333 @@ func TestHandler_repoHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("cache-per-page", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ string, opt *github.RepositoryListOptions) ([]*gh.Repository, *gh.Pagination, error) {
+				return []*gh.Repository{{ID: int64(opt.Page)}}, &gh.Pagination{Page: opt.Page, PerPage: opt.PerPage, NextPage: opt.Page + 1, LastPage: 3}, nil
+			}).Times(2)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil).Times(2)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10))
+		router := fakeHandler.SetUpRouter()
+		for _, page := range []int64{1, 2, 1, 2} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/user/karthikraobr/repositories?page=%d", page), nil)
+			router.ServeHTTP(w, req)
+			var result []*gh.Repository
+			json.NewDecoder(w.Body).Decode(&result)
+			wantNext := strconv.FormatInt(page+1, 10)
+			if !(cmp.Equal(200, w.Code) && len(result) == 1 && cmp.Equal(page, result[0].ID) && cmp.Equal(wantNext, w.Header().Get("X-Next-Page"))) {
+				t.Error("cache-per-page failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Next-want:%v got:%v", 200, w.Code, page, result, wantNext, w.Header().Get("X-Next-Page"))
+			}
+		}
+	})
+
+	t.Run("redis-cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := []*gh.Repository{{ID: 1, CreatedAt: time.Now().UTC(), Name: "blog", NodeID: "1", Owner: "me"}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(repo, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
+		client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		defer client.Close()
+		logger := log.New(ioutil.Discard, "", 0)
+		c := cache.NewRedis[any](client, logger, cache.RedisConfig{TTL: time.Minute})
+		router := New(fakeGh, logger, fakeStore, c).SetUpRouter()
+		for _, wantStatus := range []string{"miss", "fresh"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/me/repositories", nil)
+			router.ServeHTTP(w, req)
+			var result []*gh.Repository
+			json.NewDecoder(w.Body).Decode(&result)
+			status := w.Header().Get("X-Cache-Status")
+			if !(cmp.Equal(200, w.Code) && cmp.Equal(repo, result) && cmp.Equal(wantStatus, status)) {
+				t.Error("redis-cache failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Status-want:%v got:%v", 200, w.Code, repo, result, wantStatus, status)
+			}
+		}
+	})
+
 	t.Run("db-get-error", func(t *testing.T) {
 		dbErr := "db get error"
+		var logs bytes.Buffer
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		fakeGh := mock.NewMockFetcher(ctrl)
-		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("network issue"))
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeStore.EXPECT().GetRepositories(gomock.Any()).Return(nil, errors.New(dbErr))
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeStore.EXPECT().QueryRepositories(gomock.Any()).Return(nil, errors.New(dbErr))
+		fakeHandler := New(fakeGh, log.New(&logs, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories", nil)
 		router.ServeHTTP(w, req)
 		err := w.Body.String()
-		if !(cmp.Equal(500, w.Code) && strings.Contains(err, dbErr)) {
+		// The cause is logged, clients only see a sanitized detail.
+		if !(cmp.Equal(500, w.Code) && !strings.Contains(err, dbErr) && strings.Contains(logs.String(), dbErr)) {
 			t.Error("db-get-error failed")
-			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 500, w.Code, dbErr, err)
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Logs:%v", 500, w.Code, dbErr, err, logs.String())
+		}
+	})
+
+	t.Run("options-and-filters", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repos := []*gh.Repository{
+			{ID: 1, Name: "blog", Language: "Go"},
+			{ID: 2, Name: "fork", Language: "go", Fork: true},
+			{ID: 3, Name: "old", Language: "Go", Archived: true},
+			{ID: 4, Name: "site", Language: "HTML"},
+		}
+		want := &github.RepositoryListOptions{Type: "owner", Sort: "pushed", Direction: "desc", ListOptions: github.ListOptions{Page: 1, PerPage: 20}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), "karthikraobr", want).Return(repos, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+		// The filters are applied to the cached page, so only the first request calls github.
+		for query, wantIDs := range map[string][]int64{
+			"":                           {1, 2, 3, 4},
+			"language=GO&archived=false": {1, 2},
+			"type=sources&language=go":   {1, 3},
+			"type=forks":                 {2},
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories?sort=pushed&"+query, nil)
+			router.ServeHTTP(w, req)
+			var result []*gh.Repository
+			json.NewDecoder(w.Body).Decode(&result)
+			var ids []int64
+			for _, r := range result {
+				ids = append(ids, r.ID)
+			}
+			if !(cmp.Equal(200, w.Code) && cmp.Equal(wantIDs, ids)) {
+				t.Error("options-and-filters failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, wantIDs, ids)
+			}
+		}
+	})
+
+	t.Run("invalid-options", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		router := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		for query, wantErr := range map[string]string{
+			"type=private":   "invalid type",
+			"sort=stars":     "invalid sort",
+			"direction=up":   "invalid direction",
+			"archived=maybe": "invalid archived",
+			"language=a%00b": "invalid language",
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories?"+query, nil)
+			router.ServeHTTP(w, req)
+			if !(cmp.Equal(400, w.Code) && strings.Contains(w.Body.String(), wantErr)) {
+				t.Error("invalid-options failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 400, w.Code, wantErr, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("filtered-db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		// Like pages listed by github, the stored page is filtered after paging.
+		repos := []*gh.Repository{
+			{ID: 1, Name: "blog", Language: "Go", Fork: true},
+			{ID: 2, Name: "source", Language: "Go"},
+			{ID: 3, Name: "secret", Language: "Go", Fork: true, Private: true},
+		}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryRepositories(store.RepositoryQuery{
+			Owner:     "karthikraobr",
+			SortBy:    store.SortByCreatedAt,
+			Direction: store.Desc,
+			Limit:     20,
+		}).Return(&store.RepositoryResult{Repositories: repos, Total: 3}, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories?type=forks&sort=created&language=go&archived=false", nil)
+		router.ServeHTTP(w, req)
+		var result []*gh.Repository
+		json.NewDecoder(w.Body).Decode(&result)
+		want := repos[:1]
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(cacheStatusDB, w.Header().Get(cacheStatusHeader)) && cmp.Equal(want, result)) {
+			t.Error("filtered-db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, want, result)
+		}
+	})
+
+	t.Run("member-without-db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, githubError(http.StatusBadGateway))
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repositories?type=member", nil)
+		router.ServeHTTP(w, req)
+		if !cmp.Equal(502, w.Code) {
+			t.Error("member-without-db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v", 502, w.Code)
+		}
+	})
+}
+
+func TestHandler_coalescing(t *testing.T) {
+	t.Run("concurrent-misses", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		release := make(chan struct{})
+		repo := []*gh.Repository{{ID: 1, Name: "blog", Owner: "me"}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(context.Context, string, *github.RepositoryListOptions) ([]*gh.Repository, *gh.Pagination, error) {
+				<-release
+				return repo, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil
+			})
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10))
+		router := fakeHandler.SetUpRouter()
+		var wg sync.WaitGroup
+		codes := make([]int, 5)
+		results := make([][]*gh.Repository, 5)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/user/me/repositories", nil)
+				router.ServeHTTP(w, req)
+				codes[i] = w.Code
+				json.NewDecoder(w.Body).Decode(&results[i])
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		for i := range codes {
+			if !(cmp.Equal(200, codes[i]) && cmp.Equal(repo, results[i])) {
+				t.Error("concurrent-misses failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, codes[i], repo, results[i])
+			}
 		}
 	})
 
+	t.Run("waiter-cancelled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		release := make(chan struct{})
+		done := make(chan struct{})
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(context.Context, string, *github.RepositoryListOptions) ([]*gh.Repository, *gh.Pagination, error) {
+				defer close(done)
+				<-release
+				return nil, nil, errors.New("network issue")
+			})
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10))
+		router := fakeHandler.SetUpRouter()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/user/me/repositories", nil)
+		router.ServeHTTP(w, req)
+		close(release)
+		<-done
+		if ctx.Err() == nil {
+			t.Error("waiter-cancelled failed, want the request to return once its context is done")
+		}
+	})
+}
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestHandler_staleWhileRevalidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clock := &testClock{now: time.Unix(0, 0)}
+	c := cache.NewWithConfig(cache.Config[string, any]{TTL: time.Minute, StaleTTL: time.Hour, Now: clock.Now, JanitorInterval: -1})
+	t.Cleanup(c.Close)
+	old := []*gh.Repository{{ID: 1, Name: "blog", Owner: "me"}}
+	fresh := []*gh.Repository{{ID: 1, Name: "blog", Owner: "me"}, {ID: 2, Name: "site", Owner: "me"}}
+	fakeGh := mock.NewMockFetcher(ctrl)
+	gomock.InOrder(
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(old, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil),
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(fresh, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil),
+	)
+	fakeStore := mock.NewMockDB(ctrl)
+	// Like the store of old, the mock changes the repositories it is given. Run with -race, serving
+	// the repositories while they are changed fails.
+	fakeStore.EXPECT().CreateRepositories(gomock.Any()).DoAndReturn(func(repos []*gh.Repository) ([]*gh.Repository, error) {
+		for _, r := range repos {
+			r.LastAccess = time.Unix(0, 0)
+		}
+		return repos, nil
+	}).Times(2)
+	fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, c)
+	router := fakeHandler.SetUpRouter()
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/me/repositories", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// refreshed keeps serving the stale page while it is refreshed in the background.
+	refreshed := func() {
+		for i := 0; i < 1000 && serve().Header().Get(cacheStatusHeader) != cacheStatusFresh; i++ {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	tests := []struct {
+		name       string
+		before     func()
+		want       []*gh.Repository
+		wantStatus string
+	}{
+		{name: "miss", want: old, wantStatus: "miss"},
+		{name: "fresh", want: old, wantStatus: "fresh"},
+		{name: "stale", before: func() { clock.Add(2 * time.Minute) }, want: old, wantStatus: "stale"},
+		{name: "refreshed", before: refreshed, want: fresh, wantStatus: "fresh"},
+	}
+	for _, tt := range tests {
+		if tt.before != nil {
+			tt.before()
+		}
+		w := serve()
+		var result []*gh.Repository
+		json.NewDecoder(w.Body).Decode(&result)
+		status := w.Header().Get("X-Cache-Status")
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(tt.want, result) && cmp.Equal(tt.wantStatus, status)) {
+			t.Error(tt.name + " failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Status-want:%v got:%v", 200, w.Code, tt.want, result, tt.wantStatus, status)
+		}
+	}
 }
 
 func TestHandler_commitHandler(t *testing.T) {
@@ -122,9 +452,10 @@ func TestHandler_commitHandler(t *testing.T) {
 			SHA:         "sha",
 		}}
 		fakeGh := mock.NewMockFetcher(ctrl)
-		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(commits, nil)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(commits, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeStore.EXPECT().CreateCommits("karthikraobr", "myrepo", gomock.Any()).Return(nil, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits", nil)
@@ -144,7 +475,7 @@ func TestHandler_commitHandler(t *testing.T) {
 		defer ctrl.Finish()
 		fakeGh := mock.NewMockFetcher(ctrl)
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user//repository/myrepo/commits", nil)
@@ -156,13 +487,84 @@ func TestHandler_commitHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("gh-error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		commits := []*gh.Commit{{
+			Author:      "author",
+			CommentsURL: "commentURL",
+			NodeID:      "nodeid",
+			SHA:         "sha",
+		}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryCommits(store.CommitQuery{
+			Owner:      "karthikraobr",
+			Repository: "myrepo",
+			Limit:      10,
+			Offset:     10,
+		}).Return(&store.CommitResult{Commits: commits, Total: 11}, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits?page=2&perpage=10", nil)
+		router.ServeHTTP(w, req)
+		var result []*gh.Commit
+		json.NewDecoder(w.Body).Decode(&result)
+		if !(cmp.Equal(200, w.Code) && len(result) == 1 && cmp.Equal(commits[0], result[0]) && cmp.Equal("1", w.Header().Get("X-Prev-Page"))) {
+			t.Errorf("gh-error failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, commits, result)
+		}
+	})
+
+	t.Run("db-get-error", func(t *testing.T) {
+		dbErr := "db get error"
+		var logs bytes.Buffer
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryCommits(gomock.Any()).Return(nil, errors.New(dbErr))
+		fakeHandler := New(fakeGh, log.New(&logs, "", 0), fakeStore, newTestCache(t, 1))
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits", nil)
+		router.ServeHTTP(w, req)
+		err := w.Body.String()
+		// The cause is logged, clients only see a sanitized detail.
+		if !(cmp.Equal(500, w.Code) && !strings.Contains(err, dbErr) && strings.Contains(logs.String(), dbErr)) {
+			t.Error("db-get-error failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Logs:%v", 500, w.Code, dbErr, err, logs.String())
+		}
+	})
+
+	t.Run("rate-limited", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, &gh.RateLimitError{RetryAfter: 90 * time.Second})
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryCommits(gomock.Any()).Return(&store.CommitResult{}, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits", nil)
+		router.ServeHTTP(w, req)
+		if !(cmp.Equal(429, w.Code) && cmp.Equal("90", w.Header().Get("Retry-After"))) {
+			t.Errorf("rate-limited failed")
+			t.Errorf("Code-want:%vgot:%v\n Retry-After-want:%v got:%v", 429, w.Code, "90", w.Header().Get("Retry-After"))
+		}
+	})
+
 	t.Run("missing-reponame", func(t *testing.T) {
 		wantErr := "empty repo name"
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 		fakeGh := mock.NewMockFetcher(ctrl)
 		fakeStore := mock.NewMockDB(ctrl)
-		fakeHandler := New(fakeGh, &log.Logger{}, fakeStore, cache.New(1, 1))
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
 		router := fakeHandler.SetUpRouter()
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository//commits", nil)
@@ -174,4 +576,639 @@ func TestHandler_commitHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("filters", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		since := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+		want := &github.CommitsListOptions{
+			SHA:         "develop",
+			Path:        "cmd/main.go",
+			Author:      "me@example.com",
+			Since:       since,
+			ListOptions: github.ListOptions{Page: 1, PerPage: 20},
+		}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), "karthikraobr", "myrepo", want).Return([]*gh.Commit{{SHA: "sha"}}, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateCommits("karthikraobr", "myrepo", gomock.Any()).Return(nil, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+		// The second request is served from the cache, the third differs by its filters.
+		for i, query := range []string{
+			"sha=develop&path=cmd/main.go&author=me@example.com&since=2020-10-01T02:00:00%2B02:00",
+			"sha=develop&path=cmd/main.go&author=me@example.com&since=2020-10-01T00:00:00Z",
+			"sha=main",
+		} {
+			if i == 2 {
+				fakeGh.EXPECT().ListCommits(gomock.Any(), "karthikraobr", "myrepo", gomock.Any()).Return(nil, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
+				fakeStore.EXPECT().CreateCommits("karthikraobr", "myrepo", gomock.Any()).Return(nil, nil)
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits?"+query, nil)
+			router.ServeHTTP(w, req)
+			if !cmp.Equal(200, w.Code) {
+				t.Error("filters failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, query, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("invalid-filters", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		router := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		for query, wantErr := range map[string]string{
+			"since=yesterday":  "invalid since",
+			"until=2020-10-01": "invalid until",
+			"since=2020-10-02T00:00:00Z&until=2020-10-01T00:00:00Z": "invalid since",
+			"author=" + strings.Repeat("a", 256):                    "invalid author",
+			"path=a%0Ab":                                            "invalid path",
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits?"+query, nil)
+			router.ServeHTTP(w, req)
+			if !(cmp.Equal(400, w.Code) && strings.Contains(w.Body.String(), wantErr)) {
+				t.Error("invalid-filters failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 400, w.Code, wantErr, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("filtered-db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		commits := []*gh.Commit{{SHA: "sha", Author: "me"}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryCommits(store.CommitQuery{
+			Owner:      "karthikraobr",
+			Repository: "myrepo",
+			Author:     "me",
+			Until:      time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC),
+			Limit:      20,
+		}).Return(&store.CommitResult{Commits: commits, Total: 1}, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits?author=me&until=2020-10-01T00:00:00Z", nil)
+		router.ServeHTTP(w, req)
+		var result []*gh.Commit
+		json.NewDecoder(w.Body).Decode(&result)
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(commits, result) && cmp.Equal(cacheStatusDB, w.Header().Get(cacheStatusHeader))) {
+			t.Error("filtered-db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, commits, result)
+		}
+	})
+
+	t.Run("path-without-db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, githubError(http.StatusBadGateway))
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/repository/myrepo/commits?path=README.md", nil)
+		router.ServeHTTP(w, req)
+		if !cmp.Equal(502, w.Code) {
+			t.Error("path-without-db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v", 502, w.Code)
+		}
+	})
+}
+
+func TestHandler_syncHandler(t *testing.T) {
+	pagesOf := func(pages ...gh.RepositoryPage) <-chan gh.RepositoryPage {
+		ch := make(chan gh.RepositoryPage, len(pages))
+		for _, p := range pages {
+			ch <- p
+		}
+		close(ch)
+		return ch
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListAllRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(pagesOf(
+			gh.RepositoryPage{Page: 1, Repositories: []*gh.Repository{{ID: 1}, {ID: 2}}},
+			gh.RepositoryPage{Page: 2, Repositories: []*gh.Repository{{ID: 3}}},
+		))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil).Times(2)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).WithAdminToken("secret")
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/user/karthikraobr/sync", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, req)
+		var result map[string]int
+		json.NewDecoder(w.Body).Decode(&result)
+		want := map[string]int{"pages": 2, "repositories": 3}
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(want, result)) {
+			t.Error("ok")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%vgot:%v", 200, w.Code, want, result)
+		}
+	})
+
+	t.Run("gh-error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListAllRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(pagesOf(
+			gh.RepositoryPage{Page: 1, Repositories: []*gh.Repository{{ID: 1}}},
+			gh.RepositoryPage{Page: 2, Err: errors.New("network issue")},
+		))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).WithAdminToken("secret")
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/user/karthikraobr/sync", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, req)
+		if !cmp.Equal(500, w.Code) {
+			t.Errorf("gh-error failed")
+			t.Errorf("Code-want:%vgot:%v", 500, w.Code)
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		for token, want := range map[string]int{"": http.StatusNotFound, "secret": http.StatusUnauthorized} {
+			router := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).
+				WithAdminToken(token).SetUpRouter()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/user/karthikraobr/sync", nil)
+			router.ServeHTTP(w, req)
+			if !cmp.Equal(want, w.Code) {
+				t.Error("unauthorized failed")
+				t.Errorf("Code-want:%vgot:%v", want, w.Code)
+			}
+		}
+	})
+}
+
+func TestHandler_top20Handler(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := []*gh.Repository{{ID: 1, Name: "blog", Owner: "karthikraobr"}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryRepositories(store.RepositoryQuery{
+			Owner:     "karthikraobr",
+			SortBy:    store.SortByLastAccess,
+			Direction: store.Desc,
+			Limit:     20,
+			Private:   boolPtr(false),
+		}).Return(&store.RepositoryResult{Repositories: repo, Total: 1}, nil)
+		fakeHandler := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1))
+		router := fakeHandler.SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user/karthikraobr/top20", nil)
+		router.ServeHTTP(w, req)
+		var result []*gh.Repository
+		json.NewDecoder(w.Body).Decode(&result)
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(repo, result)) {
+			t.Error("ok")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%vgot:%v", 200, w.Code, repo, result)
+		}
+	})
+}
+
+func TestHandler_admin(t *testing.T) {
+	newRouter := func(t *testing.T) (http.Handler, *cache.TTLCache[string, any]) {
+		ctrl := gomock.NewController(t)
+		c := newTestCache(t, 10)
+		c.Put("repositories:me/repositories?type=owner&sort=full_name&direction=asc&page=1&perpage=20", &repositoryPage{})
+		c.Put("commits:me/blog/commits?sha=&path=&author=&since=&until=&page=1&perpage=20", &commitPage{})
+		c.Put("repositories:you/repositories?type=owner&sort=full_name&direction=asc&page=1&perpage=20", &repositoryPage{})
+		h := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), c).WithAdminToken("secret")
+		return h.SetUpRouter(), c
+	}
+	serve := func(router http.Handler, method, url, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		router, _ := newRouter(t)
+		for _, token := range []string{"", "wrong"} {
+			w := serve(router, "GET", "/admin/cache/stats", token)
+			if !cmp.Equal(401, w.Code) {
+				t.Error("unauthorized failed")
+				t.Errorf("Code-want:%vgot:%v", 401, w.Code)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		router := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		w := serve(router, "GET", "/admin/cache/stats", "")
+		if !cmp.Equal(404, w.Code) {
+			t.Error("disabled failed")
+			t.Errorf("Code-want:%vgot:%v", 404, w.Code)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		router, c := newRouter(t)
+		c.Get("repositories:me/repositories?type=owner&sort=full_name&direction=asc&page=1&perpage=20")
+		c.Get("missing")
+		w := serve(router, "GET", "/admin/cache/stats", "secret")
+		var result cacheStats
+		json.NewDecoder(w.Body).Decode(&result)
+		want := cacheStats{Stats: cache.Stats{Hits: 1, Misses: 1, Entries: 3}, HitRatio: 0.5}
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(want, result)) {
+			t.Error("stats failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, want, result)
+		}
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		router, _ := newRouter(t)
+		w := serve(router, "GET", "/admin/cache/keys?prefix=me/", "secret")
+		var result map[string][]string
+		json.NewDecoder(w.Body).Decode(&result)
+		want := map[string][]string{
+			"repositories": {"me/repositories?type=owner&sort=full_name&direction=asc&page=1&perpage=20"},
+			"commits":      {"me/blog/commits?sha=&path=&author=&since=&until=&page=1&perpage=20"},
+			"failures":     {},
+			"owners":       {},
+		}
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(want, result)) {
+			t.Error("keys failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, want, result)
+		}
+	})
+
+	t.Run("purge-prefix", func(t *testing.T) {
+		router, c := newRouter(t)
+		w := serve(router, "DELETE", "/admin/cache?prefix=me/", "secret")
+		if !(cmp.Equal(200, w.Code) && strings.Contains(w.Body.String(), `"purged":2`) && cmp.Equal(1, c.Len())) {
+			t.Error("purge-prefix failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Len-want:%v got:%v", 200, w.Code, 2, w.Body.String(), 1, c.Len())
+		}
+	})
+
+	t.Run("purge-key", func(t *testing.T) {
+		router, c := newRouter(t)
+		w := serve(router, "DELETE", "/admin/cache?key="+url.QueryEscape("me/blog/commits?sha=&path=&author=&since=&until=&page=1&perpage=20"), "secret")
+		if !(cmp.Equal(200, w.Code) && strings.Contains(w.Body.String(), `"purged":1`) && cmp.Equal(2, c.Len())) {
+			t.Error("purge-key failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Len-want:%v got:%v", 200, w.Code, 1, w.Body.String(), 2, c.Len())
+		}
+	})
+
+	t.Run("purge-missing-params", func(t *testing.T) {
+		router, _ := newRouter(t)
+		w := serve(router, "DELETE", "/admin/cache", "secret")
+		if !cmp.Equal(400, w.Code) {
+			t.Error("purge-missing-params failed")
+			t.Errorf("Code-want:%vgot:%v", 400, w.Code)
+		}
+	})
+}
+
+func githubError(status int) *github.ErrorResponse {
+	req, _ := http.NewRequest("GET", "https://api.github.com/users/nobody/repos", nil)
+	return &github.ErrorResponse{Response: &http.Response{StatusCode: status, Request: req}, Message: http.StatusText(status)}
+}
+
+func TestHandler_negativeCaching(t *testing.T) {
+	blocked := githubError(http.StatusForbidden)
+	blocked.Block = &struct {
+		Reason    string            `json:"reason,omitempty"`
+		CreatedAt *github.Timestamp `json:"created_at,omitempty"`
+	}{Reason: "dmca"}
+	tests := map[string]struct {
+		err         error
+		wantCode    int
+		wantFetches int
+	}{
+		"not-found":     {err: githubError(http.StatusNotFound), wantCode: 404, wantFetches: 1},
+		"legal-reasons": {err: githubError(http.StatusUnavailableForLegalReasons), wantCode: 451, wantFetches: 1},
+		"blocked":       {err: blocked, wantCode: 403, wantFetches: 1},
+		"forbidden":     {err: githubError(http.StatusForbidden), wantCode: 401, wantFetches: 2},
+		"rate-limited":  {err: &gh.RateLimitError{RetryAfter: time.Minute, Err: githubError(http.StatusForbidden)}, wantCode: 429, wantFetches: 2},
+		"server-error":  {err: githubError(http.StatusBadGateway), wantCode: 502, wantFetches: 2},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			fakeGh := mock.NewMockFetcher(ctrl)
+			fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, tt.err).Times(tt.wantFetches)
+			fakeStore := mock.NewMockDB(ctrl)
+			fakeStore.EXPECT().QueryRepositories(gomock.Any()).Return(&store.RepositoryResult{}, nil).AnyTimes()
+			router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/user/nobody/repositories", nil)
+				router.ServeHTTP(w, req)
+				if !cmp.Equal(tt.wantCode, w.Code) {
+					t.Error(name + " failed")
+					t.Errorf("Code-want:%vgot:%v\n Result:%v", tt.wantCode, w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+
+	t.Run("commits-expire", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		clock := &testClock{now: time.Unix(0, 0)}
+		c := cache.NewWithConfig(cache.Config[string, any]{TTL: time.Minute, Now: clock.Now, JanitorInterval: -1})
+		t.Cleanup(c.Close)
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListCommits(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, githubError(http.StatusNotFound)).Times(2)
+		fakeStore := mock.NewMockDB(ctrl)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, c).WithNegativeTTL(10 * time.Second).SetUpRouter()
+		for _, tt := range []struct {
+			after      time.Duration
+			wantStatus string
+		}{{0, "miss"}, {5 * time.Second, "fresh"}, {6 * time.Second, "miss"}} {
+			clock.Add(tt.after)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/nobody/repository/nothing/commits", nil)
+			router.ServeHTTP(w, req)
+			status := w.Header().Get("X-Cache-Status")
+			if !(cmp.Equal(404, w.Code) && cmp.Equal(tt.wantStatus, status)) {
+				t.Error("commits-expire failed")
+				t.Errorf("Code-want:%vgot:%v\n Status-want:%v got:%v", 404, w.Code, tt.wantStatus, status)
+			}
+		}
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	tests := map[string]struct {
+		err       error
+		requestID string
+		want      map[string]interface{}
+		wantCode  int
+	}{
+		"http-error": {
+			err:      NewHttpError(http.StatusBadRequest, errors.New("empty username")),
+			want:     map[string]interface{}{"code": "validation", "title": "Bad Request", "detail": "empty username"},
+			wantCode: 400,
+		},
+		"not-found": {
+			err:      githubError(http.StatusNotFound),
+			want:     map[string]interface{}{"code": "not_found", "title": "Not Found", "detail": "the user or repository does not exist on github"},
+			wantCode: 404,
+		},
+		"rate-limited": {
+			err:      &gh.RateLimitError{RetryAfter: 90 * time.Second},
+			want:     map[string]interface{}{"code": "rate_limited", "title": "Too Many Requests", "detail": "the github api rate limit is exhausted", "retry_after": float64(90)},
+			wantCode: 429,
+		},
+		"timeout": {
+			err:      fmt.Errorf("listing commits: %w", context.DeadlineExceeded),
+			want:     map[string]interface{}{"code": "timeout", "title": "Gateway Timeout", "detail": "github did not answer in time"},
+			wantCode: 504,
+		},
+		"internal": {
+			err:       errors.New(`pq: relation "repositories" does not exist`),
+			requestID: "abc",
+			want:      map[string]interface{}{"code": "internal", "title": "Internal Server Error", "detail": "an internal error occurred"},
+			wantCode:  500,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(RequestID(), ErrorHandler(log.New(ioutil.Discard, "", 0)))
+			router.GET("/user/me/repositories", func(c *gin.Context) { c.Error(tt.err) })
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user/me/repositories", nil)
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+			router.ServeHTTP(w, req)
+			var result map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&result)
+			want := tt.want
+			want["type"] = "urn:gh-fetch:problem:" + want["code"].(string)
+			want["status"] = float64(tt.wantCode)
+			want["instance"] = "/user/me/repositories"
+			want["request_id"] = w.Header().Get("X-Request-ID")
+			if tt.requestID != "" && tt.requestID != want["request_id"] {
+				t.Errorf("X-Request-ID = %v, want the id sent by the client", want["request_id"])
+			}
+			contentType := w.Header().Get("Content-Type")
+			if !(cmp.Equal(tt.wantCode, w.Code) && cmp.Equal(want, result) && cmp.Equal("application/problem+json", contentType)) {
+				t.Error(name + " failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v Content-Type:%v", tt.wantCode, w.Code, want, result, contentType)
+			}
+		})
+	}
+}
+
+func TestHandler_circuitBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := []*gh.Repository{{ID: 1, Name: "blog", Owner: "karthikraobr"}}
+	fakeGh := mock.NewMockFetcher(ctrl)
+	fakeGh.EXPECT().ListRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, githubError(http.StatusBadGateway))
+	fakeStore := mock.NewMockDB(ctrl)
+	fakeStore.EXPECT().QueryRepositories(gomock.Any()).Return(&store.RepositoryResult{Repositories: repo, Total: 1}, nil).Times(2)
+	breaker := gh.NewBreaker(fakeGh, gh.BreakerConfig{Threshold: 1})
+	router := New(breaker, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).SetUpRouter()
+	serve := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/health")
+	if !(cmp.Equal(200, w.Code) && strings.Contains(w.Body.String(), `"status":"ok"`) && strings.Contains(w.Body.String(), `"circuit":"closed"`)) {
+		t.Error("closed failed")
+		t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, "closed", w.Body.String())
+	}
+	// The second request goes straight to the store without calling github.
+	for _, page := range []int{1, 2} {
+		w := serve(fmt.Sprintf("/user/karthikraobr/repositories?page=%d", page))
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(cacheStatusDB, w.Header().Get(cacheStatusHeader))) {
+			t.Error("fallback failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, cacheStatusDB, w.Header().Get(cacheStatusHeader))
+		}
+	}
+	w = serve("/health")
+	if !(cmp.Equal(200, w.Code) && strings.Contains(w.Body.String(), `"status":"degraded"`) && strings.Contains(w.Body.String(), `"circuit":"open"`)) {
+		t.Error("open failed")
+		t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, "open", w.Body.String())
+	}
+}
+
+func TestHandler_orgRepoHandler(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repos := []*gh.Repository{
+			{ID: 1, Name: "gh-fetch", Owner: "org", OwnerType: gh.OwnerOrganization},
+			{ID: 2, Name: "secret", Owner: "org", OwnerType: gh.OwnerOrganization, Private: true},
+		}
+		want := &github.RepositoryListByOrgOptions{Type: "member", Sort: "full_name", Direction: "asc", ListOptions: github.ListOptions{Page: 1, PerPage: 20}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListOrgRepositories(gomock.Any(), "org", want).Return(repos, &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}, nil)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(repos).Return(nil, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/org/org/repositories?type=member", nil)
+		router.ServeHTTP(w, req)
+		var result []*gh.Repository
+		json.NewDecoder(w.Body).Decode(&result)
+		// Private repositories reachable with the token of the service are not listed.
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(repos[:1], result)) {
+			t.Error("ok failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, repos[:1], result)
+		}
+	})
+
+	t.Run("invalid-type", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		router := New(mock.NewMockFetcher(ctrl), log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		for _, typ := range []string{"owner", "private", "internal"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/org/org/repositories?type="+typ, nil)
+			router.ServeHTTP(w, req)
+			if !(cmp.Equal(400, w.Code) && strings.Contains(w.Body.String(), "invalid type")) {
+				t.Error("invalid-type failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 400, w.Code, "invalid type", w.Body.String())
+			}
+		}
+	})
+
+	t.Run("db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repos := []*gh.Repository{{ID: 1, Name: "gh-fetch", Owner: "org"}}
+		fork := false
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListOrgRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, errors.New("network issue"))
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().QueryRepositories(store.RepositoryQuery{
+			Owner:     "org",
+			SortBy:    store.SortByName,
+			Direction: store.Asc,
+			Limit:     10,
+			Offset:    10,
+			Fork:      &fork,
+		}).Return(&store.RepositoryResult{Repositories: repos, Total: 11}, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/org/org/repositories?type=sources&page=2&perpage=10", nil)
+		router.ServeHTTP(w, req)
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(cacheStatusDB, w.Header().Get(cacheStatusHeader)) && cmp.Equal("1", w.Header().Get("X-Prev-Page"))) {
+			t.Error("db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, repos, w.Body.String())
+		}
+	})
+
+	t.Run("member-without-db-fallback", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().ListOrgRepositories(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, nil, githubError(http.StatusGatewayTimeout))
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 1)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/org/org/repositories?type=member", nil)
+		router.ServeHTTP(w, req)
+		if !cmp.Equal(504, w.Code) {
+			t.Error("member-without-db-fallback failed")
+			t.Errorf("Code-want:%vgot:%v", 504, w.Code)
+		}
+	})
+}
+
+func TestHandler_ownerRepoHandler(t *testing.T) {
+	page := &gh.Pagination{Page: 1, PerPage: 20, LastPage: 1}
+
+	t.Run("detects-org", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		// The owner type is cached, the second request only lists another page.
+		fakeGh.EXPECT().GetOwnerType(gomock.Any(), "org").Return(gh.OwnerOrganization, nil)
+		fakeGh.EXPECT().ListOrgRepositories(gomock.Any(), "org", gomock.Any()).Return(nil, page, nil).Times(2)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil).Times(2)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+		for _, p := range []int{1, 2} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("/owner/org/repositories?page=%d", p), nil)
+			router.ServeHTTP(w, req)
+			if !cmp.Equal(200, w.Code) {
+				t.Error("detects-org failed")
+				t.Errorf("Code-want:%vgot:%v", 200, w.Code)
+			}
+		}
+	})
+
+	t.Run("detects-user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().GetOwnerType(gomock.Any(), "me").Return(gh.OwnerUser, nil)
+		fakeGh.EXPECT().ListRepositories(gomock.Any(), "me", gomock.Any()).Return(nil, page, nil)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().CreateRepositories(gomock.Any()).Return(nil, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/owner/me/repositories", nil)
+		router.ServeHTTP(w, req)
+		if !cmp.Equal(200, w.Code) {
+			t.Error("detects-user failed")
+			t.Errorf("Code-want:%vgot:%v", 200, w.Code)
+		}
+	})
+
+	t.Run("stored-owner-type", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repos := []*gh.Repository{{ID: 1, Name: "gh-fetch", Owner: "org", OwnerType: gh.OwnerOrganization}}
+		fakeGh := mock.NewMockFetcher(ctrl)
+		fakeGh.EXPECT().GetOwnerType(gomock.Any(), "org").Return(gh.OwnerType(""), gh.ErrCircuitOpen)
+		fakeGh.EXPECT().ListOrgRepositories(gomock.Any(), "org", gomock.Any()).Return(nil, nil, gh.ErrCircuitOpen)
+		fakeStore := mock.NewMockDB(ctrl)
+		fakeStore.EXPECT().GetOwnerType("org").Return(gh.OwnerOrganization, nil)
+		fakeStore.EXPECT().QueryRepositories(gomock.Any()).Return(&store.RepositoryResult{Repositories: repos, Total: 1}, nil)
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), fakeStore, newTestCache(t, 10)).SetUpRouter()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/owner/org/repositories", nil)
+		router.ServeHTTP(w, req)
+		if !(cmp.Equal(200, w.Code) && cmp.Equal(cacheStatusDB, w.Header().Get(cacheStatusHeader))) {
+			t.Error("stored-owner-type failed")
+			t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 200, w.Code, repos, w.Body.String())
+		}
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		fakeGh := mock.NewMockFetcher(ctrl)
+		// The failure is remembered, github is only asked once.
+		fakeGh.EXPECT().GetOwnerType(gomock.Any(), "nobody").Return(gh.OwnerType(""), githubError(http.StatusNotFound))
+		router := New(fakeGh, log.New(ioutil.Discard, "", 0), mock.NewMockDB(ctrl), newTestCache(t, 10)).SetUpRouter()
+		for _, want := range []string{cacheStatusMiss, cacheStatusFresh} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/owner/nobody/repositories", nil)
+			router.ServeHTTP(w, req)
+			if !(cmp.Equal(404, w.Code) && cmp.Equal(want, w.Header().Get(cacheStatusHeader))) {
+				t.Error("not-found failed")
+				t.Errorf("Code-want:%vgot:%v\n Result-want:%v got:%v", 404, w.Code, want, w.Header().Get(cacheStatusHeader))
+			}
+		}
+	})
 }
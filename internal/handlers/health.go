@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+)
+
+// circuitBreaker is implemented by fetchers which stop calling github while it is failing.
+type circuitBreaker interface {
+	State() gh.BreakerState
+}
+
+// HandleHealth reports whether github is reachable. The service keeps answering from the store
+// while the circuit breaker is open, which is why it is reported as degraded rather than failing.
+func (h *Handler) HandleHealth() func(c *gin.Context) {
+	return h.healthHandler
+}
+
+func (h *Handler) healthHandler(c *gin.Context) {
+	res := gin.H{"status": "ok"}
+	if b, ok := h.client.(circuitBreaker); ok {
+		state := b.State()
+		if state != gh.BreakerClosed {
+			res["status"] = "degraded"
+		}
+		res["github"] = gin.H{"circuit": state}
+	}
+	c.JSON(http.StatusOK, res)
+}
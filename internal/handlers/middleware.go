@@ -1,36 +1,206 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
 	"github.com/pkg/errors"
 )
 
+const (
+	// requestIDHeader carries the id of a request, error responses repeat it to correlate them with the logs.
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+	// maxRequestIDLength caps the length of ids sent by clients.
+	maxRequestIDLength = 64
+	// problemContentType is the media type of RFC 7807 problem details.
+	problemContentType = "application/problem+json"
+	// problemTypePrefix prefixes the code of an error to form the type URI of its problem.
+	problemTypePrefix = "urn:gh-fetch:problem:"
+)
+
+// HttpError is rendered as an RFC 7807 problem. The inner error is only shown to clients for
+// statuses below 500, other errors are logged and replaced by a sanitized detail.
 type HttpError struct {
 	status     int
 	innerError error
+	header     http.Header
+	detail     string
+	extensions map[string]interface{}
 }
 
 func (e *HttpError) Error() string {
 	return e.innerError.Error()
 }
 
+func (e *HttpError) Unwrap() error {
+	return e.innerError
+}
+
 func NewHttpError(status int, err error) *HttpError {
 	return &HttpError{status: status, innerError: err}
 }
 
-func ErrorHandler() gin.HandlerFunc {
+// WithHeader adds a header which is sent along with the error response.
+func (e *HttpError) WithHeader(key, value string) *HttpError {
+	if e.header == nil {
+		e.header = make(http.Header)
+	}
+	e.header.Set(key, value)
+	return e
+}
+
+// WithDetail sets the explanation of the problem shown to clients in place of the inner error.
+func (e *HttpError) WithDetail(detail string) *HttpError {
+	e.detail = detail
+	return e
+}
+
+// WithExtension adds a member to the problem.
+func (e *HttpError) WithExtension(key string, value interface{}) *HttpError {
+	if e.extensions == nil {
+		e.extensions = make(map[string]interface{})
+	}
+	e.extensions[key] = value
+	return e
+}
+
+// problem returns the problem details of the error which occurred for instance.
+func (e *HttpError) problem(instance string) map[string]interface{} {
+	p := make(map[string]interface{}, len(e.extensions)+6)
+	for k, v := range e.extensions {
+		p[k] = v
+	}
+	code := e.code()
+	detail := e.detail
+	if detail == "" {
+		detail = "an internal error occurred"
+		if e.status < http.StatusInternalServerError {
+			detail = e.Error()
+		}
+	}
+	p["type"] = problemTypePrefix + code
+	p["title"] = http.StatusText(e.status)
+	p["status"] = e.status
+	p["detail"] = detail
+	p["instance"] = instance
+	p["code"] = code
+	return p
+}
+
+// code is the machine readable name of the status of the error.
+func (e *HttpError) code() string {
+	switch e.status {
+	case http.StatusBadRequest:
+		return gh.KindValidation.String()
+	case http.StatusUnauthorized:
+		return gh.KindUnauthorized.String()
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return gh.KindNotFound.String()
+	case http.StatusTooManyRequests:
+		return gh.KindRateLimited.String()
+	case http.StatusUnavailableForLegalReasons:
+		return "unavailable_for_legal_reasons"
+	case http.StatusBadGateway:
+		return gh.KindUnavailable.String()
+	case http.StatusGatewayTimeout:
+		return gh.KindTimeout.String()
+	}
+	return "internal"
+}
+
+// RequestID assigns every request an id, reusing the one sent by the client if any.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ErrorHandler responds with the last error of a request as a problem. Errors with a status
+// of 500 and above are logged along with their cause.
+func ErrorHandler(log *log.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 		err := c.Errors.Last()
-		if err == nil {
+		if err == nil || c.Writer.Written() {
 			return
 		}
-		var httpErr *HttpError
-		if errors.As(errors.Cause(err.Err), &httpErr) {
-			c.JSON(httpErr.status, gin.H{
-				"error": httpErr.Error(),
-			})
+		requestID := c.GetString(requestIDKey)
+		httpErr := toHttpError(err.Err)
+		if httpErr.status >= http.StatusInternalServerError {
+			log.Printf("request %s %s %s failed: %v", requestID, c.Request.Method, c.Request.URL.Path, httpErr.innerError)
+		}
+		for k, v := range httpErr.header {
+			c.Writer.Header()[k] = v
+		}
+		body, _ := json.Marshal(httpErr.WithExtension(requestIDKey, requestID).problem(c.Request.URL.Path))
+		c.Data(httpErr.status, problemContentType, body)
+	}
+}
+
+// toHttpError maps err to a response. Errors of the github client are mapped by their kind.
+func toHttpError(err error) *HttpError {
+	var httpErr *HttpError
+	if errors.As(errors.Cause(err), &httpErr) {
+		return httpErr
+	}
+	// The messages of github errors carry upstream urls, which is why they are replaced.
+	switch gh.Classify(err) {
+	case gh.KindNotFound:
+		return NewHttpError(http.StatusNotFound, err).WithDetail("the user or repository does not exist on github")
+	case gh.KindUnauthorized:
+		return NewHttpError(http.StatusUnauthorized, err).WithDetail("github rejected the credentials of the service")
+	case gh.KindRateLimited:
+		httpErr := NewHttpError(http.StatusTooManyRequests, err).WithDetail("the github api rate limit is exhausted")
+		var rateErr *gh.RateLimitError
+		if errors.As(err, &rateErr) {
+			retryAfter := int(math.Ceil(rateErr.RetryAfter.Seconds()))
+			httpErr.WithHeader("Retry-After", strconv.Itoa(retryAfter)).WithExtension("retry_after", retryAfter)
+		}
+		return httpErr
+	case gh.KindUnavailable:
+		return NewHttpError(http.StatusBadGateway, err).WithDetail("github could not be reached")
+	case gh.KindTimeout:
+		return NewHttpError(http.StatusGatewayTimeout, err).WithDetail("github did not answer in time")
+	case gh.KindValidation:
+		return NewHttpError(http.StatusBadRequest, err).WithDetail("github rejected the parameters of the request")
+	}
+	return NewHttpError(http.StatusInternalServerError, err)
+}
+
+// AdminAuth only lets requests through which carry token as a bearer token.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			c.Error(NewHttpError(http.StatusUnauthorized, errors.New("invalid admin token")).WithHeader("WWW-Authenticate", "Bearer"))
+			c.Abort()
 			return
 		}
+		c.Next()
 	}
 }
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+)
+
+// defaultNegativeTTL is how long github answering that something does not exist is remembered.
+const defaultNegativeTTL = 30 * time.Second
+
+// upstreamFailure is a cached github answer that a user or repository does not exist or is blocked.
+type upstreamFailure struct {
+	Status  int
+	Message string
+}
+
+func init() {
+	gob.Register(&upstreamFailure{})
+}
+
+func (f *upstreamFailure) httpError() *HttpError {
+	detail := "the user or repository does not exist on github"
+	switch f.Status {
+	case http.StatusUnavailableForLegalReasons:
+		detail = "the user or repository is unavailable for legal reasons"
+	case http.StatusForbidden:
+		detail = "access to the user or repository is blocked"
+	}
+	return NewHttpError(f.Status, errors.New(f.Message)).WithDetail(detail)
+}
+
+// negativeFailure returns the failure to remember for err, which is only the case for
+// missing resources, resources unavailable for legal reasons and blocked resources.
+func negativeFailure(err error) (*upstreamFailure, bool) {
+	var rateErr *gh.RateLimitError
+	if errors.As(err, &rateErr) {
+		return nil, false
+	}
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return nil, false
+	}
+	switch status := errResp.Response.StatusCode; {
+	case status == http.StatusNotFound, status == http.StatusUnavailableForLegalReasons,
+		status == http.StatusForbidden && errResp.Block != nil:
+		return &upstreamFailure{Status: status, Message: err.Error()}, true
+	}
+	return nil, false
+}
+
+// rememberFailure caches err under key for the negative TTL when github told us the resource is not there.
+func (h *Handler) rememberFailure(key string, err error) {
+	if f, ok := negativeFailure(err); ok {
+		h.failures.PutWithTTL(key, f, h.negativeTTL)
+	}
+}
+
+// WithNegativeTTL sets how long github answering that a user or repository does not exist is remembered.
+func (h *Handler) WithNegativeTTL(ttl time.Duration) *Handler {
+	h.negativeTTL = ttl
+	return h
+}
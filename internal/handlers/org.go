@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+)
+
+// ownerTypeTTL is how long the type of an account is cached, accounts rarely change their type.
+const ownerTypeTTL = 24 * time.Hour
+
+// HandleOrgRepositories fetches the repositories of a gh organization.
+func (h *Handler) HandleOrgRepositories() func(c *gin.Context) {
+	return h.orgRepoHandler
+}
+
+func (h *Handler) orgRepoHandler(c *gin.Context) {
+	org := c.Param("org")
+	if org == "" {
+		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty org")))
+		return
+	}
+	h.orgRepositories(c, org)
+}
+
+// orgRepositories serves a page of the repositories of an organization.
+func (h *Handler) orgRepositories(c *gin.Context, org string) {
+	page, perPage := pageParams(c)
+	opt, filter, err := orgRepositoryOptions(c, page, perPage)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	l := repositoryListing{
+		owner:  org,
+		key:    orgRepositoriesKey(org, &opt),
+		filter: filter,
+		list: func(ctx context.Context) ([]*gh.Repository, *gh.Pagination, error) {
+			return h.client.ListOrgRepositories(ctx, org, &opt)
+		},
+	}
+	// The store does not know the members of an organization.
+	switch opt.Type {
+	case "all", "public":
+		l.query = repositoryQuery(org, opt.Sort, opt.Direction, opt.ListOptions)
+	case "forks", "sources":
+		// Github pages the forks or sources of an organization alone, so does the store.
+		l.query = repositoryQuery(org, opt.Sort, opt.Direction, opt.ListOptions)
+		l.query.Fork = filter.fork
+	}
+	h.serveRepositories(c, l)
+}
+
+// HandleOwnerRepositories fetches the repositories of a gh user or organization, whichever the name belongs to.
+func (h *Handler) HandleOwnerRepositories() func(c *gin.Context) {
+	return h.ownerRepoHandler
+}
+
+func (h *Handler) ownerRepoHandler(c *gin.Context) {
+	owner := c.Param("owner")
+	if owner == "" {
+		c.Error(NewHttpError(http.StatusBadRequest, errors.New("empty owner")))
+		return
+	}
+	// An owner github does not know has no repositories either, both share the remembered failure.
+	fKey := owner + "/repositories"
+	if f, ok := h.failures.Get(fKey); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusFresh))
+		return
+	}
+	t, err := h.ownerType(c.Request.Context(), owner, fKey)
+	if clientGone(c) {
+		return
+	}
+	if f, ok := negativeFailure(err); ok {
+		c.Error(f.httpError().WithHeader(cacheStatusHeader, cacheStatusMiss))
+		return
+	}
+	if err != nil {
+		c.Error(upstreamError(err, NewHttpError(http.StatusInternalServerError, err)))
+		return
+	}
+	if t == gh.OwnerOrganization {
+		h.orgRepositories(c, owner)
+		return
+	}
+	h.userRepositories(c, owner)
+}
+
+// ownerType tells whether name is a user or an organization. When github cannot tell, the
+// owner type of the stored repositories of name is used. Github not knowing name is remembered under fKey.
+func (h *Handler) ownerType(ctx context.Context, name, fKey string) (gh.OwnerType, error) {
+	if t, ok := h.owners.Get(name); ok {
+		return t, nil
+	}
+	t, err := h.client.GetOwnerType(ctx, name)
+	if err == nil {
+		h.owners.PutWithTTL(name, t, ownerTypeTTL)
+		return t, nil
+	}
+	if _, ok := negativeFailure(err); ok {
+		h.rememberFailure(fKey, err)
+		return "", err
+	}
+	stored, dbErr := h.store.GetOwnerType(name)
+	if dbErr != nil {
+		h.log.Println("could not read owner type", name, dbErr.Error())
+	}
+	if stored == "" {
+		return "", err
+	}
+	return stored, nil
+}
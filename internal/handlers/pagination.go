@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v32/github"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+)
+
+const (
+	defaultPerPage = 20
+	// maxPerPage is the largest page github serves.
+	maxPerPage = 100
+)
+
+// repositoryPage is a cached page of repositories.
+type repositoryPage struct {
+	Repositories []*gh.Repository
+	Pagination   gh.Pagination
+}
+
+// commitPage is a cached page of commits.
+type commitPage struct {
+	Commits    []*gh.Commit
+	Pagination gh.Pagination
+}
+
+func init() {
+	// Shared caches store pages gob encoded.
+	gob.Register(&repositoryPage{})
+	gob.Register(&commitPage{})
+	gob.Register(gh.OwnerType(""))
+}
+
+// pageParams reads the page and perpage query parameters, falling back to defaults for invalid values.
+func pageParams(c *gin.Context) (page int, perPage int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(c.DefaultQuery("perpage", strconv.Itoa(defaultPerPage)))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+// repositoriesKey is the cache key of a page of repositories. It covers every option sent to github.
+func repositoriesKey(username string, opt *github.RepositoryListOptions) string {
+	return fmt.Sprintf("%s/repositories?type=%s&sort=%s&direction=%s&page=%d&perpage=%d",
+		username, opt.Type, opt.Sort, opt.Direction, opt.Page, opt.PerPage)
+}
+
+// orgRepositoriesKey is the cache key of a page of the repositories of an organization. It covers every option sent to github.
+func orgRepositoriesKey(org string, opt *github.RepositoryListByOrgOptions) string {
+	return fmt.Sprintf("%s/org-repositories?type=%s&sort=%s&direction=%s&page=%d&perpage=%d",
+		org, opt.Type, opt.Sort, opt.Direction, opt.Page, opt.PerPage)
+}
+
+// commitsKey is the cache key of a page of commits. It covers every option sent to github.
+func commitsKey(username, repo string, opt *github.CommitsListOptions) string {
+	return fmt.Sprintf("%s/%s/commits?sha=%s&path=%s&author=%s&since=%s&until=%s&page=%d&perpage=%d",
+		username, repo, url.QueryEscape(opt.SHA), url.QueryEscape(opt.Path), url.QueryEscape(opt.Author),
+		formatTime(opt.Since), formatTime(opt.Until), opt.Page, opt.PerPage)
+}
+
+// formatTime formats t for a cache key, zero times are empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// storePagination locates a page of stored rows out of total rows.
+func storePagination(page, perPage int, total int64) *gh.Pagination {
+	p := &gh.Pagination{Page: page, PerPage: perPage}
+	p.LastPage = int((total + int64(perPage) - 1) / int64(perPage))
+	if p.LastPage < 1 {
+		p.LastPage = 1
+	}
+	if page < p.LastPage {
+		p.NextPage = page + 1
+	}
+	if page > 1 {
+		p.PrevPage = page - 1
+	}
+	return p
+}
+
+// writePagination sends the pagination as X-Page, X-Per-Page, X-Next-Page, X-Prev-Page and X-Last-Page
+// headers and as a github style Link header.
+func writePagination(c *gin.Context, p *gh.Pagination) {
+	c.Header("X-Page", strconv.Itoa(p.Page))
+	c.Header("X-Per-Page", strconv.Itoa(p.PerPage))
+	c.Header("X-Next-Page", strconv.Itoa(p.NextPage))
+	c.Header("X-Prev-Page", strconv.Itoa(p.PrevPage))
+	c.Header("X-Last-Page", strconv.Itoa(p.LastPage))
+	var links []string
+	for _, l := range []struct {
+		rel  string
+		page int
+	}{{"next", p.NextPage}, {"prev", p.PrevPage}, {"first", 1}, {"last", p.LastPage}} {
+		if l.page > 0 {
+			links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, pageURL(c.Request.URL, l.page), l.rel))
+		}
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+func pageURL(u *url.URL, page int) string {
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).String()
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "github.com/karthikraobr/gh-fetch/internal/cache"
+
+// cacheStatusHeader tells clients where the data of a response came from.
+const cacheStatusHeader = "X-Cache-Status"
+
+const (
+	// cacheStatusFresh responses were served from the cache within their TTL.
+	cacheStatusFresh = "fresh"
+	// cacheStatusStale responses were served from the cache past their TTL while being refreshed in the background.
+	cacheStatusStale = "stale"
+	// cacheStatusMiss responses were fetched from github.
+	cacheStatusMiss = "miss"
+	// cacheStatusDB responses were served from the store because github could not be reached.
+	cacheStatusDB = "db"
+)
+
+func cacheStatus(state cache.State) string {
+	if state == cache.Stale {
+		return cacheStatusStale
+	}
+	return cacheStatusFresh
+}
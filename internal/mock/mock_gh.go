@@ -36,12 +36,13 @@ func (m *MockFetcher) EXPECT() *MockFetcherMockRecorder {
 }
 
 // ListRepositories mocks base method
-func (m *MockFetcher) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*gh.Repository, error) {
+func (m *MockFetcher) ListRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) ([]*gh.Repository, *gh.Pagination, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ListRepositories", ctx, username, opt)
 	ret0, _ := ret[0].([]*gh.Repository)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(*gh.Pagination)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // ListRepositories indicates an expected call of ListRepositories
@@ -50,13 +51,28 @@ func (mr *MockFetcherMockRecorder) ListRepositories(ctx, username, opt interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRepositories", reflect.TypeOf((*MockFetcher)(nil).ListRepositories), ctx, username, opt)
 }
 
+// ListAllRepositories mocks base method
+func (m *MockFetcher) ListAllRepositories(ctx context.Context, username string, opt *github.RepositoryListOptions) <-chan gh.RepositoryPage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllRepositories", ctx, username, opt)
+	ret0, _ := ret[0].(<-chan gh.RepositoryPage)
+	return ret0
+}
+
+// ListAllRepositories indicates an expected call of ListAllRepositories
+func (mr *MockFetcherMockRecorder) ListAllRepositories(ctx, username, opt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllRepositories", reflect.TypeOf((*MockFetcher)(nil).ListAllRepositories), ctx, username, opt)
+}
+
 // ListCommits mocks base method
-func (m *MockFetcher) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*gh.Commit, error) {
+func (m *MockFetcher) ListCommits(ctx context.Context, username, repoName string, opt *github.CommitsListOptions) ([]*gh.Commit, *gh.Pagination, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ListCommits", ctx, username, repoName, opt)
 	ret0, _ := ret[0].([]*gh.Commit)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(*gh.Pagination)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // ListCommits indicates an expected call of ListCommits
@@ -64,3 +80,34 @@ func (mr *MockFetcherMockRecorder) ListCommits(ctx, username, repoName, opt inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCommits", reflect.TypeOf((*MockFetcher)(nil).ListCommits), ctx, username, repoName, opt)
 }
+
+// ListOrgRepositories mocks base method
+func (m *MockFetcher) ListOrgRepositories(ctx context.Context, org string, opt *github.RepositoryListByOrgOptions) ([]*gh.Repository, *gh.Pagination, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOrgRepositories", ctx, org, opt)
+	ret0, _ := ret[0].([]*gh.Repository)
+	ret1, _ := ret[1].(*gh.Pagination)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListOrgRepositories indicates an expected call of ListOrgRepositories
+func (mr *MockFetcherMockRecorder) ListOrgRepositories(ctx, org, opt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrgRepositories", reflect.TypeOf((*MockFetcher)(nil).ListOrgRepositories), ctx, org, opt)
+}
+
+// GetOwnerType mocks base method
+func (m *MockFetcher) GetOwnerType(ctx context.Context, name string) (gh.OwnerType, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOwnerType", ctx, name)
+	ret0, _ := ret[0].(gh.OwnerType)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOwnerType indicates an expected call of GetOwnerType
+func (mr *MockFetcherMockRecorder) GetOwnerType(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOwnerType", reflect.TypeOf((*MockFetcher)(nil).GetOwnerType), ctx, name)
+}
@@ -7,6 +7,7 @@ package mock
 import (
 	gomock "github.com/golang/mock/gomock"
 	gh "github.com/karthikraobr/gh-fetch/internal/gh"
+	store "github.com/karthikraobr/gh-fetch/internal/store"
 	reflect "reflect"
 )
 
@@ -63,19 +64,34 @@ func (mr *MockDBMockRecorder) CreateRepository(r interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRepository", reflect.TypeOf((*MockDB)(nil).CreateRepository), r)
 }
 
-// GetRepositories mocks base method
-func (m *MockDB) GetRepositories(username string) ([]*gh.Repository, error) {
+// QueryRepositories mocks base method
+func (m *MockDB) QueryRepositories(q store.RepositoryQuery) (*store.RepositoryResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRepositories", username)
-	ret0, _ := ret[0].([]*gh.Repository)
+	ret := m.ctrl.Call(m, "QueryRepositories", q)
+	ret0, _ := ret[0].(*store.RepositoryResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryRepositories indicates an expected call of QueryRepositories
+func (mr *MockDBMockRecorder) QueryRepositories(q interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRepositories", reflect.TypeOf((*MockDB)(nil).QueryRepositories), q)
+}
+
+// GetOwnerType mocks base method
+func (m *MockDB) GetOwnerType(owner string) (gh.OwnerType, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOwnerType", owner)
+	ret0, _ := ret[0].(gh.OwnerType)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetRepositories indicates an expected call of GetRepositories
-func (mr *MockDBMockRecorder) GetRepositories(username interface{}) *gomock.Call {
+// GetOwnerType indicates an expected call of GetOwnerType
+func (mr *MockDBMockRecorder) GetOwnerType(owner interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositories", reflect.TypeOf((*MockDB)(nil).GetRepositories), username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOwnerType", reflect.TypeOf((*MockDB)(nil).GetOwnerType), owner)
 }
 
 // CreateRepositories mocks base method
@@ -93,17 +109,32 @@ func (mr *MockDBMockRecorder) CreateRepositories(r interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRepositories", reflect.TypeOf((*MockDB)(nil).CreateRepositories), r)
 }
 
-// GetRepositoriesOrderedBy mocks base method
-func (m *MockDB) GetRepositoriesOrderedBy(username string, limit int, sort, sortBy string) ([]*gh.Repository, error) {
+// CreateCommits mocks base method
+func (m *MockDB) CreateCommits(owner, repo string, c []*gh.Commit) ([]*gh.Commit, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetRepositoriesOrderedBy", username, limit, sort, sortBy)
-	ret0, _ := ret[0].([]*gh.Repository)
+	ret := m.ctrl.Call(m, "CreateCommits", owner, repo, c)
+	ret0, _ := ret[0].([]*gh.Commit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCommits indicates an expected call of CreateCommits
+func (mr *MockDBMockRecorder) CreateCommits(owner, repo, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCommits", reflect.TypeOf((*MockDB)(nil).CreateCommits), owner, repo, c)
+}
+
+// QueryCommits mocks base method
+func (m *MockDB) QueryCommits(q store.CommitQuery) (*store.CommitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryCommits", q)
+	ret0, _ := ret[0].(*store.CommitResult)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetRepositoriesOrderedBy indicates an expected call of GetRepositoriesOrderedBy
-func (mr *MockDBMockRecorder) GetRepositoriesOrderedBy(username, limit, sort, sortBy interface{}) *gomock.Call {
+// QueryCommits indicates an expected call of QueryCommits
+func (mr *MockDBMockRecorder) QueryCommits(q interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositoriesOrderedBy", reflect.TypeOf((*MockDB)(nil).GetRepositoriesOrderedBy), username, limit, sort, sortBy)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryCommits", reflect.TypeOf((*MockDB)(nil).QueryCommits), q)
 }
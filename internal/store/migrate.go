@@ -0,0 +1,73 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+	"gorm.io/gorm"
+)
+
+// migrate brings the schema up to date. AutoMigrate adds missing tables, columns and indexes,
+// changes it cannot make are migrated explicitly.
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&gh.Repository{}, &gh.Commit{}, &gh.CachedResponse{}); err != nil {
+		return err
+	}
+	if err := migrateRepositoryColumns(db); err != nil {
+		return err
+	}
+	return migrateCommitKey(db)
+}
+
+// repositoryDefaults are the values of the columns repositories gained after they were first stored.
+// AutoMigrate adds them as NULL, which filters like private = false do not match.
+var repositoryDefaults = []struct {
+	column string
+	value  interface{}
+}{
+	{"owner_type", ""},
+	{"description", ""},
+	{"html_url", ""},
+	{"default_branch", ""},
+	{"language", ""},
+	{"stars", 0},
+	{"forks", 0},
+	{"open_issues", 0},
+	{"archived", false},
+	{"fork", false},
+	{"private", false},
+	{"pushed_at", time.Time{}},
+	{"github_updated_at", time.Time{}},
+}
+
+// migrateRepositoryColumns backfills the columns of repositories stored before the columns existed.
+func migrateRepositoryColumns(db *gorm.DB) error {
+	var set, null []string
+	var values []interface{}
+	for _, d := range repositoryDefaults {
+		set = append(set, d.column+" = coalesce("+d.column+", ?)")
+		null = append(null, d.column+" IS NULL")
+		values = append(values, d.value)
+	}
+	return db.Exec("UPDATE repositories SET "+strings.Join(set, ", ")+" WHERE "+strings.Join(null, " OR "), values...).Error
+}
+
+// migrateCommitKey replaces the primary key of commits, which used to be the SHA alone, by the
+// repository along with the SHA. A SHA is shared by a repository and its forks.
+func migrateCommitKey(db *gorm.DB) error {
+	var columns int64
+	err := db.Raw(`SELECT count(*) FROM information_schema.key_column_usage
+		WHERE table_schema = current_schema() AND table_name = 'commits' AND constraint_name = 'commits_pkey'`).
+		Scan(&columns).Error
+	if err != nil || columns != 1 {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE commits SET owner = coalesce(owner, ''), repository = coalesce(repository, '')
+			WHERE owner IS NULL OR repository IS NULL`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE commits DROP CONSTRAINT commits_pkey, ADD PRIMARY KEY (owner, repository, sha)`).Error
+	})
+}
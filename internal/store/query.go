@@ -0,0 +1,290 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQuery is returned for queries with unknown sort fields, directions or malformed cursors.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// SortField is a column repositories can be sorted by.
+type SortField string
+
+const (
+	SortByID         SortField = "id"
+	SortByName       SortField = "name"
+	SortByCreatedAt  SortField = "created_at"
+	SortByLastAccess SortField = "last_access"
+	SortByPushedAt   SortField = "pushed_at"
+	SortByUpdatedAt  SortField = "github_updated_at"
+)
+
+// Direction is a sort direction.
+type Direction string
+
+const (
+	Asc  Direction = "asc"
+	Desc Direction = "desc"
+)
+
+// RepositoryQuery selects a page of the repositories of an owner.
+type RepositoryQuery struct {
+	Owner string
+	// SortBy defaults to SortByID. Ties are broken by id.
+	SortBy SortField
+	// Direction defaults to Asc.
+	Direction Direction
+	// Limit caps the number of rows returned, zero means no limit.
+	Limit  int
+	Offset int
+	// After continues a listing after the row the cursor points to. Offset is ignored when set.
+	After string
+	// NamePrefix only selects repositories whose name starts with the prefix.
+	NamePrefix string
+	// CreatedAfter only selects repositories created after the time when set.
+	CreatedAfter time.Time
+	// Language only selects repositories written in the language, ignoring case.
+	Language string
+	// Archived, Fork and Private only select repositories with the flag set or unset when not nil.
+	Archived *bool
+	Fork     *bool
+	Private  *bool
+}
+
+// RepositoryResult is a page of repositories.
+type RepositoryResult struct {
+	Repositories []*gh.Repository
+	// Total is the number of repositories matching the filters of the query across all pages.
+	Total int64
+	// Next is the cursor of the last row returned, empty when no rows were returned.
+	Next string
+}
+
+// cursor points to a row of a keyset paginated listing.
+type cursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    int64           `json:"id"`
+}
+
+func (q *RepositoryQuery) normalize() error {
+	if q.SortBy == "" {
+		q.SortBy = SortByID
+	}
+	if q.Direction == "" {
+		q.Direction = Asc
+	}
+	switch q.SortBy {
+	case SortByID, SortByName, SortByCreatedAt, SortByLastAccess, SortByPushedAt, SortByUpdatedAt:
+	default:
+		return errors.Wrapf(ErrInvalidQuery, "unknown sort field %q", q.SortBy)
+	}
+	switch q.Direction {
+	case Asc, Desc:
+	default:
+		return errors.Wrapf(ErrInvalidQuery, "unknown sort direction %q", q.Direction)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return errors.Wrap(ErrInvalidQuery, "negative limit or offset")
+	}
+	return nil
+}
+
+// sortValue returns the value of the sort field of a row.
+func (q *RepositoryQuery) sortValue(r *gh.Repository) interface{} {
+	switch q.SortBy {
+	case SortByName:
+		return r.Name
+	case SortByCreatedAt:
+		return r.CreatedAt
+	case SortByLastAccess:
+		return r.LastAccess
+	case SortByPushedAt:
+		return r.PushedAt
+	case SortByUpdatedAt:
+		return r.GithubUpdatedAt
+	default:
+		return r.ID
+	}
+}
+
+// encodeCursor returns the opaque cursor pointing to r.
+func (q *RepositoryQuery) encodeCursor(r *gh.Repository) string {
+	v, _ := json.Marshal(q.sortValue(r))
+	b, _ := json.Marshal(cursor{Value: v, ID: r.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor returns the sort value and id a cursor points to.
+func (q *RepositoryQuery) decodeCursor(s string) (interface{}, int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, 0, errors.Wrap(ErrInvalidQuery, "malformed cursor")
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, 0, errors.Wrap(ErrInvalidQuery, "malformed cursor")
+	}
+	var name string
+	var t time.Time
+	var id int64
+	var v interface{}
+	switch q.SortBy {
+	case SortByName:
+		err = json.Unmarshal(c.Value, &name)
+		v = name
+	case SortByCreatedAt, SortByLastAccess, SortByPushedAt, SortByUpdatedAt:
+		err = json.Unmarshal(c.Value, &t)
+		v = t
+	default:
+		err = json.Unmarshal(c.Value, &id)
+		v = id
+	}
+	if err != nil {
+		return nil, 0, errors.Wrap(ErrInvalidQuery, "cursor does not match sort field")
+	}
+	return v, c.ID, nil
+}
+
+// filter applies the filters of the query shared by the count and the page.
+func (q *RepositoryQuery) filter(db *gorm.DB) *gorm.DB {
+	db = db.Where("owner = ?", q.Owner)
+	if q.NamePrefix != "" {
+		db = db.Where("name LIKE ?", escapeLike(q.NamePrefix)+"%")
+	}
+	if !q.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", q.CreatedAfter)
+	}
+	if q.Language != "" {
+		db = db.Where("lower(language) = lower(?)", q.Language)
+	}
+	// The flags are NULL for repositories stored by a service which did not know them yet, e.g. while
+	// it is being rolled out. Those are taken to be unset like github does for missing flags.
+	if q.Archived != nil {
+		db = db.Where("coalesce(archived, false) = ?", *q.Archived)
+	}
+	if q.Fork != nil {
+		db = db.Where("coalesce(fork, false) = ?", *q.Fork)
+	}
+	if q.Private != nil {
+		db = db.Where("coalesce(private, false) = ?", *q.Private)
+	}
+	return db
+}
+
+// QueryRepositories fetches the repositories selected by q.
+func (s *Store) QueryRepositories(q RepositoryQuery) (*RepositoryResult, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	res := &RepositoryResult{}
+	if err := q.filter(s.db.Model(&gh.Repository{})).Count(&res.Total).Error; err != nil {
+		return nil, err
+	}
+	// The sort field and direction are whitelisted in normalize.
+	db := q.filter(s.db).Order(fmt.Sprintf("%s %s, id %s", q.SortBy, q.Direction, q.Direction))
+	if q.After != "" {
+		v, id, err := q.decodeCursor(q.After)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if q.Direction == Desc {
+			op = "<"
+		}
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", q.SortBy, op), v, id)
+	} else if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	if err := db.Find(&res.Repositories).Error; err != nil {
+		return nil, err
+	}
+	if n := len(res.Repositories); n > 0 {
+		res.Next = q.encodeCursor(res.Repositories[n-1])
+	}
+	return res, nil
+}
+
+// CommitQuery selects a page of the commits of a repository, newest first.
+type CommitQuery struct {
+	Owner      string
+	Repository string
+	// Author only selects commits whose author has the github login or the email.
+	Author string
+	// Since and Until only select commits committed within the range when set.
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of rows returned, zero means no limit.
+	Limit  int
+	Offset int
+}
+
+// CommitResult is a page of commits.
+type CommitResult struct {
+	Commits []*gh.Commit
+	// Total is the number of commits matching the filters of the query across all pages.
+	Total int64
+}
+
+func (q *CommitQuery) normalize() error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return errors.Wrap(ErrInvalidQuery, "negative limit or offset")
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && q.Since.After(q.Until) {
+		return errors.Wrap(ErrInvalidQuery, "since is after until")
+	}
+	return nil
+}
+
+// filter applies the filters of the query shared by the count and the page.
+func (q *CommitQuery) filter(db *gorm.DB) *gorm.DB {
+	db = db.Where("owner = ? AND repository = ?", q.Owner, q.Repository)
+	if q.Author != "" {
+		db = db.Where("(author = ? OR author_email = ?)", q.Author, q.Author)
+	}
+	if !q.Since.IsZero() {
+		db = db.Where("committed_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		db = db.Where("committed_at <= ?", q.Until)
+	}
+	return db
+}
+
+// QueryCommits fetches the commits selected by q.
+func (s *Store) QueryCommits(q CommitQuery) (*CommitResult, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	res := &CommitResult{}
+	if err := q.filter(s.db.Model(&gh.Commit{})).Count(&res.Total).Error; err != nil {
+		return nil, err
+	}
+	db := q.filter(s.db).Order("committed_at desc, sha")
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	if err := db.Find(&res.Commits).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
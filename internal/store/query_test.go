@@ -0,0 +1,112 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/karthikraobr/gh-fetch/internal/gh"
+)
+
+func TestRepositoryQuery_normalize(t *testing.T) {
+	tests := map[string]struct {
+		q       RepositoryQuery
+		want    RepositoryQuery
+		wantErr bool
+	}{
+		"defaults": {
+			q:    RepositoryQuery{Owner: "me"},
+			want: RepositoryQuery{Owner: "me", SortBy: SortByID, Direction: Asc},
+		},
+		"unknown-sort-field": {
+			q:       RepositoryQuery{SortBy: "name; drop table repositories"},
+			wantErr: true,
+		},
+		"unknown-direction": {
+			q:       RepositoryQuery{Direction: "sideways"},
+			wantErr: true,
+		},
+		"negative-offset": {
+			q:       RepositoryQuery{Offset: -1},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.q.normalize()
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidQuery)) {
+				t.Errorf("RepositoryQuery.normalize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !cmp.Equal(tt.q, tt.want) {
+				t.Errorf("RepositoryQuery.normalize() = %v, want %v", tt.q, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepositoryQuery_cursor(t *testing.T) {
+	repo := &gh.Repository{
+		ID:        7,
+		Name:      "blog",
+		CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		PushedAt:  time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC),
+	}
+	tests := map[SortField]interface{}{
+		SortByID:        int64(7),
+		SortByName:      "blog",
+		SortByCreatedAt: repo.CreatedAt,
+		SortByPushedAt:  repo.PushedAt,
+	}
+	for field, want := range tests {
+		t.Run(string(field), func(t *testing.T) {
+			q := RepositoryQuery{SortBy: field}
+			v, id, err := q.decodeCursor(q.encodeCursor(repo))
+			if err != nil {
+				t.Fatalf("RepositoryQuery.decodeCursor() error = %v", err)
+			}
+			if !cmp.Equal(v, want) || id != repo.ID {
+				t.Errorf("RepositoryQuery.decodeCursor() = %v, %v, want %v, %v", v, id, want, repo.ID)
+			}
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		q := RepositoryQuery{SortBy: SortByCreatedAt}
+		if _, _, err := q.decodeCursor("not a cursor"); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("RepositoryQuery.decodeCursor() error = %v, want %v", err, ErrInvalidQuery)
+		}
+	})
+}
+
+func TestCommitQuery_normalize(t *testing.T) {
+	day := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		q       CommitQuery
+		wantErr bool
+	}{
+		"range": {
+			q: CommitQuery{Since: day, Until: day.Add(time.Hour)},
+		},
+		"open-range": {
+			q: CommitQuery{Since: day},
+		},
+		"since-after-until": {
+			q:       CommitQuery{Since: day.Add(time.Hour), Until: day},
+			wantErr: true,
+		},
+		"negative-offset": {
+			q:       CommitQuery{Offset: -1},
+			wantErr: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.q.normalize()
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidQuery)) {
+				t.Errorf("CommitQuery.normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"log"
 	"time"
 
@@ -20,7 +21,7 @@ func New(connectionString string, log *log.Logger) (*Store, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := db.AutoMigrate(&gh.Repository{}, &gh.Commit{}); err != nil {
+	if err := migrate(db); err != nil {
 		return nil, err
 	}
 	log.Println("db init successful")
@@ -34,9 +35,11 @@ func New(connectionString string, log *log.Logger) (*Store, error) {
 type DB interface {
 	GetRepository(id int64) (*gh.Repository, error)
 	CreateRepository(r *gh.Repository) (*gh.Repository, error)
-	GetRepositories(username string) ([]*gh.Repository, error)
+	QueryRepositories(q RepositoryQuery) (*RepositoryResult, error)
+	GetOwnerType(owner string) (gh.OwnerType, error)
 	CreateRepositories(r []*gh.Repository) ([]*gh.Repository, error)
-	GetRepositoriesOrderedBy(username string, limit int, sort string, sortBy string) ([]*gh.Repository, error)
+	CreateCommits(owner, repo string, c []*gh.Commit) ([]*gh.Commit, error)
+	QueryCommits(q CommitQuery) (*CommitResult, error)
 }
 
 // GetRepository fetches a single github repository by ID.
@@ -49,6 +52,20 @@ func (s *Store) GetRepository(id int64) (*gh.Repository, error) {
 	return &repo, nil
 }
 
+// GetOwnerType returns whether the stored repositories of owner belong to a user or an organization.
+// It is empty when no repository of the owner is stored with its owner type.
+func (s *Store) GetOwnerType(owner string) (gh.OwnerType, error) {
+	var repo gh.Repository
+	err := s.db.Select("owner_type").Where("owner = ? AND owner_type <> ''", owner).First(&repo).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return repo.OwnerType, nil
+}
+
 // CreateRepository creates a repository.
 func (s *Store) CreateRepository(r *gh.Repository) (*gh.Repository, error) {
 	r.LastAccess = time.Now()
@@ -59,42 +76,115 @@ func (s *Store) CreateRepository(r *gh.Repository) (*gh.Repository, error) {
 	return r, nil
 }
 
-// GetRepositories fetches all the repository of a user.
-func (s *Store) GetRepositories(username string) ([]*gh.Repository, error) {
-	var repo []*gh.Repository
-	result := s.db.Where("owner = ?", username).Find(&repo)
-	if result.Error != nil {
-		return nil, result.Error
+// CreateRepositories creates repositories if not present, otherwise it refreshes what github
+// reported about them along with their last access, in a transaction. The repositories of the
+// caller are left untouched, they may be shared with concurrent readers. The stored copies are returned.
+func (s *Store) CreateRepositories(r []*gh.Repository) ([]*gh.Repository, error) {
+	stored := make([]*gh.Repository, 0, len(r))
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, in := range r {
+			v := *in
+			v.LastAccess = time.Now()
+			stored = append(stored, &v)
+			var new gh.Repository
+			if err := tx.Where(gh.Repository{ID: v.ID}).Attrs(v).FirstOrCreate(&new).Error; err != nil {
+				return err
+			}
+			if v.LastAccess != new.LastAccess {
+				if err := tx.Model(&new).Updates(repositoryColumns(&v)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	return repo, nil
+	return stored, nil
 }
 
-//GetRepositoriesOrderedBy fetches limit number of repositories sorted by sortby in sort order.
-func (s *Store) GetRepositoriesOrderedBy(username string, limit int, sort string, sortBy string) ([]*gh.Repository, error) {
-	var repo []*gh.Repository
-	result := s.db.Order(sortBy+" "+sort).Limit(limit).Where("owner = ?", username).Find(&repo)
-	if result.Error != nil {
-		return nil, result.Error
+// repositoryColumns are the columns of a stored repository refreshed from github. A map is used
+// since updating from a struct would skip zero values, e.g. a repository which is no longer archived.
+func repositoryColumns(r *gh.Repository) map[string]interface{} {
+	return map[string]interface{}{
+		"node_id":           r.NodeID,
+		"owner":             r.Owner,
+		"owner_type":        r.OwnerType,
+		"name":              r.Name,
+		"description":       r.Description,
+		"html_url":          r.HTMLURL,
+		"default_branch":    r.DefaultBranch,
+		"language":          r.Language,
+		"topics":            r.Topics,
+		"stars":             r.Stars,
+		"forks":             r.Forks,
+		"open_issues":       r.OpenIssues,
+		"archived":          r.Archived,
+		"fork":              r.Fork,
+		"private":           r.Private,
+		"pushed_at":         r.PushedAt,
+		"github_updated_at": r.GithubUpdatedAt,
+		"last_access":       r.LastAccess,
 	}
-	return repo, nil
 }
 
-// CreateRepositories creates if not present or updates last access of repositories in a transaction.
-func (s *Store) CreateRepositories(r []*gh.Repository) ([]*gh.Repository, error) {
+// CreateCommits creates or updates the commits of a repository in a transaction.
+// The commits are linked to the repository when it is already stored. Like CreateRepositories
+// it leaves the commits of the caller untouched and returns the stored copies.
+func (s *Store) CreateCommits(owner, repo string, c []*gh.Commit) ([]*gh.Commit, error) {
+	stored := make([]*gh.Commit, 0, len(c))
 	if err := s.db.Transaction(func(tx *gorm.DB) error {
-		for _, v := range r {
-			v.LastAccess = time.Now()
-			var new gh.Repository
-			if err := tx.Where(gh.Repository{ID: v.ID}).Attrs(*v).FirstOrCreate(&new).Error; err != nil {
+		var repository gh.Repository
+		err := tx.Where(&gh.Repository{Owner: owner, Name: repo}).First(&repository).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		for _, in := range c {
+			v := *in
+			v.Owner = owner
+			v.Repository = repo
+			v.RepositoryID = repository.ID
+			if err := tx.Save(&v).Error; err != nil {
 				return err
 			}
-			if v.LastAccess != new.LastAccess {
-				tx.Model(&new).Updates(gh.Repository{LastAccess: v.LastAccess})
-			}
+			stored = append(stored, &v)
 		}
 		return nil
 	}); err != nil {
 		return nil, err
 	}
-	return r, nil
+	return stored, nil
+}
+
+// GetResponse fetches the stored github response of a request URL. It implements gh.ResponseCache.
+func (s *Store) GetResponse(url string) (*gh.CachedResponse, error) {
+	var resp gh.CachedResponse
+	result := s.db.Where("url = ?", url).First(&resp)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &resp, nil
+}
+
+// PutResponse creates or replaces the stored github response of a request URL. It implements gh.ResponseCache.
+func (s *Store) PutResponse(r *gh.CachedResponse) error {
+	return s.db.Save(r).Error
+}
+
+// PruneResponses bounds the stored github responses. Responses updated more than maxAge ago are deleted,
+// then all but the maxCount most recently updated ones. It returns how many responses were deleted.
+func (s *Store) PruneResponses(maxAge time.Duration, maxCount int) (int64, error) {
+	result := s.db.Where("updated_at < ?", time.Now().Add(-maxAge)).Delete(&gh.CachedResponse{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	deleted := result.RowsAffected
+	result = s.db.Exec("DELETE FROM cached_responses WHERE url NOT IN (SELECT url FROM cached_responses ORDER BY updated_at DESC LIMIT ?)", maxCount)
+	if result.Error != nil {
+		return deleted, result.Error
+	}
+	return deleted + result.RowsAffected, nil
 }